@@ -0,0 +1,37 @@
+package internal
+
+import (
+    "fmt";
+    "os";
+    "path/filepath";
+)
+
+//Writes data to path by way of a temporary file in the same directory, so a crash mid-write never leaves a half written file behind
+func writeFileAtomic(path string, data []byte) error {
+    dir := filepath.Dir(path)
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return fmt.Errorf("Failed to create directory %v with error: %v", dir, err)
+    }
+    tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+    if err != nil {
+        return fmt.Errorf("Failed to create temporary file with error: %v", err)
+    }
+    tmpName := tmp.Name()
+    // Only removes something if we bail out before the rename
+    defer os.Remove(tmpName)
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return fmt.Errorf("Failed to write %v with error: %v", tmpName, err)
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return fmt.Errorf("Failed to sync %v with error: %v", tmpName, err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("Failed to close %v with error: %v", tmpName, err)
+    }
+    if err := os.Rename(tmpName, path); err != nil {
+        return fmt.Errorf("Failed to move %v into place with error: %v", path, err)
+    }
+    return nil
+}
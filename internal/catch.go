@@ -0,0 +1,75 @@
+package internal
+
+import (
+    "math/rand/v2";
+)
+
+const (
+    // Base experience at which a Pokemon becomes close to uncatchable, Blissey sits at 608
+    catchExperienceScale = 700.0
+    minCatchChance = 0.05
+    maxCatchChance = 0.95
+)
+
+// How a Pokemon shows up in a LocationArea, summed up over all versions
+type EncounterStats struct {
+    Chance      int
+    MinLevel    int
+    MaxLevel    int
+    // Method of the most likely encounter, e.g. walk or old-rod
+    Method      string
+}
+
+//Looks up how pokemon can be encountered in the area, ok is false if it does not appear there
+func (a LocationArea) EncounterStats(pokemon string) (stats EncounterStats, ok bool) {
+    for _, encounter := range a.PokemonEncounters {
+        if encounter.Pokemon.Name != pokemon {
+            continue
+        }
+        ok = true
+        methodChance := 0
+        for _, version := range encounter.VersionDetails {
+            if version.MaxChance > stats.Chance {
+                stats.Chance = version.MaxChance
+            }
+            for _, detail := range version.EncounterDetails {
+                if stats.Method == "" || detail.Chance > methodChance {
+                    stats.Method = detail.Method.Name
+                    methodChance = detail.Chance
+                }
+                if stats.MinLevel == 0 || detail.MinLevel < stats.MinLevel {
+                    stats.MinLevel = detail.MinLevel
+                }
+                if detail.MaxLevel > stats.MaxLevel {
+                    stats.MaxLevel = detail.MaxLevel
+                }
+            }
+        }
+    }
+    return stats, ok
+}
+
+//Picks the level of a single encounter within the level range
+func (s EncounterStats) RollLevel(rng *rand.Rand) int {
+    if s.MaxLevel <= s.MinLevel {
+        return s.MinLevel
+    }
+    return s.MinLevel + rng.IntN(s.MaxLevel-s.MinLevel+1)
+}
+
+//Chance between 0 and 1 to catch a Pokemon. High base experience, rare encounters and high levels all make it harder
+func CatchChance(baseExperience int, encounterChance int, level int) float64 {
+    chance := 1 - float64(baseExperience)/catchExperienceScale
+    if encounterChance > 0 && encounterChance <= 100 {
+        chance *= 0.75 + 0.25*float64(encounterChance)/100
+    }
+    if level > 0 {
+        chance *= 1 - float64(min(level, 100))/200
+    }
+    return max(minCatchChance, min(maxCatchChance, chance))
+}
+
+//Rolls rng against CatchChance
+func TryCatch(rng *rand.Rand, baseExperience int, encounterChance int, level int) bool {
+    return rng.Float64() < CatchChance(baseExperience, encounterChance, level)
+}
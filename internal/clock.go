@@ -0,0 +1,39 @@
+package internal
+
+import (
+    "time";
+)
+
+// Source of time for the Cache. Swap it out through NewCacheWithClock to drive expiry by hand
+type Clock interface {
+    Now() time.Time
+    NewTicker(d time.Duration) Ticker
+}
+
+// The parts of time.Ticker the Cache needs
+type Ticker interface {
+    C() <-chan time.Time
+    Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+    return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+    return realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTicker struct {
+    ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+    return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+    t.ticker.Stop()
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+    "bufio";
+    "bytes";
+    "crypto/sha256";
+    "encoding/hex";
+    "encoding/json";
+    "errors";
+    "fmt";
+    "io";
+    "io/fs";
+    "os";
+    "path/filepath";
+    "sort";
+    "strings";
+    "time";
+)
+
+// On-disk tier of the Cache, one file per key named after the hash of the key. A file starts with a single JSON line
+// describing the entry and the raw value follows it, so listing the store only has to decode the first lines
+type diskStore struct {
+    dir         string
+    maxBytes    int64
+}
+
+type diskHeader struct {
+    Format          int             `json:"format"`
+    Key             string          `json:"key"`
+    CreatedAt       time.Time       `json:"created_at"`
+    ETag            string          `json:"etag,omitempty"`
+    LastModified    string          `json:"last_modified,omitempty"`
+    MaxAge          time.Duration   `json:"max_age,omitempty"`
+}
+
+const diskEntrySuffix = ".json"
+
+// Bump whenever the file layout changes. Files written in another format are dropped when the store is listed
+const diskFormat = 2
+
+func newDiskStore(dir string, maxBytes int64) (*diskStore, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("Failed to create cache directory %v with error: %v", dir, err)
+    }
+    return &diskStore{
+        dir:        dir,
+        maxBytes:   maxBytes,
+    }, nil
+}
+
+func (d *diskStore) pathFor(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return filepath.Join(d.dir, hex.EncodeToString(sum[:])+diskEntrySuffix)
+}
+
+func (d *diskStore) put(key string, entry cacheEntry) error {
+    header, err := json.Marshal(diskHeader{
+        Format:         diskFormat,
+        Key:            key,
+        CreatedAt:      entry.createdAt,
+        ETag:           entry.validators.ETag,
+        LastModified:   entry.validators.LastModified,
+        MaxAge:         entry.validators.MaxAge,
+    })
+    if err != nil {
+        return fmt.Errorf("Failed to marshal cache entry %v with error: %v", key, err)
+    }
+    data := make([]byte, 0, len(header)+1+len(entry.val))
+    data = append(append(append(data, header...), '\n'), entry.val...)
+    if err := writeFileAtomic(d.pathFor(key), data); err != nil {
+        return err
+    }
+    return d.trim()
+}
+
+func (d *diskStore) get(key string) (cacheEntry, bool) {
+    data, err := os.ReadFile(d.pathFor(key))
+    if err != nil {
+        return cacheEntry{}, false
+    }
+    line, val, found := bytes.Cut(data, []byte{'\n'})
+    var header diskHeader
+    // A file we cannot read back, from another format or belonging to a colliding key is as good as a miss
+    if !found || json.Unmarshal(line, &header) != nil || header.Format != diskFormat || header.Key != key {
+        return cacheEntry{}, false
+    }
+    return cacheEntry{
+        createdAt:  header.CreatedAt,
+        val:        val,
+        validators: Validators{
+            ETag:           header.ETag,
+            LastModified:   header.LastModified,
+            MaxAge:         header.MaxAge,
+        },
+    }, true
+}
+
+//Decodes the header line of the file at path without reading the value behind it
+func readDiskHeader(path string) (diskHeader, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return diskHeader{}, err
+    }
+    defer f.Close()
+    // Files of older formats are a single JSON document without a line break
+    line, err := bufio.NewReader(f).ReadBytes('\n')
+    if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+        return diskHeader{}, err
+    }
+    var header diskHeader
+    if err := json.Unmarshal(line, &header); err != nil {
+        return diskHeader{}, err
+    }
+    return header, nil
+}
+
+// Summary of one file in the store
+type diskEntryInfo struct {
+    key         string
+    createdAt   time.Time
+    size        int64
+}
+
+//All readable entries in the store
+func (d *diskStore) entries() []diskEntryInfo {
+    dirEntries, err := os.ReadDir(d.dir)
+    if err != nil {
+        return nil
+    }
+    var infos []diskEntryInfo
+    for _, dirEntry := range dirEntries {
+        if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), diskEntrySuffix) {
+            continue
+        }
+        path := filepath.Join(d.dir, dirEntry.Name())
+        info, err := dirEntry.Info()
+        if err != nil {
+            continue
+        }
+        header, err := readDiskHeader(path)
+        if err != nil {
+            continue
+        }
+        // Nothing can read these anymore, e.g. files of the single JSON document layout of older versions
+        if header.Format != diskFormat {
+            os.Remove(path)
+            continue
+        }
+        infos = append(infos, diskEntryInfo{
+            key:        header.Key,
+            createdAt:  header.CreatedAt,
+            size:       info.Size(),
+        })
+    }
+    return infos
+}
+
+//Reports whether there was a file for key
+func (d *diskStore) remove(key string) bool {
+    return os.Remove(d.pathFor(key)) == nil
+}
+
+//Deletes the oldest files until the store fits into maxBytes again. A maxBytes of 0 or less means unbounded
+func (d *diskStore) trim() error {
+    if d.maxBytes <= 0 {
+        return nil
+    }
+    dirEntries, err := os.ReadDir(d.dir)
+    if err != nil {
+        return fmt.Errorf("Failed to read cache directory %v with error: %v", d.dir, err)
+    }
+    type file struct {
+        path    string
+        size    int64
+        modTime time.Time
+    }
+    var files []file
+    var total int64
+    for _, dirEntry := range dirEntries {
+        if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), diskEntrySuffix) {
+            continue
+        }
+        info, err := dirEntry.Info()
+        if err != nil {
+            continue
+        }
+        files = append(files, file{
+            path:       filepath.Join(d.dir, dirEntry.Name()),
+            size:       info.Size(),
+            modTime:    info.ModTime(),
+        })
+        total += info.Size()
+    }
+    sort.Slice(files, func(i, j int) bool {
+        return files[i].modTime.Before(files[j].modTime)
+    })
+    for _, f := range files {
+        if total <= d.maxBytes {
+            break
+        }
+        // Another trim running at the same time may have been faster
+        if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+            return fmt.Errorf("Failed to remove cache file %v with error: %v", f.path, err)
+        }
+        total -= f.size
+    }
+    return nil
+}
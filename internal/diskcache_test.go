@@ -0,0 +1,54 @@
+package internal
+
+import (
+    "os";
+    "path/filepath";
+    "reflect";
+    "testing";
+)
+
+func TestDiskEntriesSurviveRestart(t *testing.T) {
+    dir := t.TempDir()
+    c := NewCacheWithClock(0, newFakeClock())
+    if err := c.Persist(dir, 0); err != nil {
+        t.Fatalf("Persist failed: %v", err)
+    }
+    c.Put("b", []byte("line one\nline two"), Validators{ETag: `"etag"`})
+    c.Put("a", []byte("val"), Validators{})
+    c.Close()
+
+    restarted := NewCacheWithClock(0, newFakeClock())
+    defer restarted.Close()
+    if err := restarted.Persist(dir, 0); err != nil {
+        t.Fatalf("Persist failed: %v", err)
+    }
+    if keys := restarted.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+        t.Fatalf("Keys = %v, want [a b]", keys)
+    }
+    cached, ok := restarted.Lookup("b")
+    if !ok || string(cached.Val) != "line one\nline two" || cached.Validators.ETag != `"etag"` {
+        t.Fatalf("Lookup = %+v, %v, want the stored value and ETag", cached, ok)
+    }
+}
+
+func TestDiskEntriesOfOldFormatAreDropped(t *testing.T) {
+    dir := t.TempDir()
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    if err := c.Persist(dir, 0); err != nil {
+        t.Fatalf("Persist failed: %v", err)
+    }
+    old := c.disk.pathFor("old")
+    if err := os.WriteFile(old, []byte(`{"key":"old","created_at":"2024-01-01T00:00:00Z","val":"dmFs"}`), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := c.Get("old"); ok {
+        t.Fatal("Get read a file of the old format")
+    }
+    if keys := c.Keys(); len(keys) != 0 {
+        t.Fatalf("Keys = %v, want none", keys)
+    }
+    if _, err := os.Stat(old); !os.IsNotExist(err) {
+        t.Fatalf("old file %v is still there", filepath.Base(old))
+    }
+}
@@ -0,0 +1,201 @@
+// Package fakeapi serves a small fixture backed stand-in for PokeAPI, meant to be pointed at with Client.SetBaseURL
+package fakeapi
+
+import (
+    "crypto/sha256";
+    "embed";
+    "encoding/json";
+    "fmt";
+    "io/fs";
+    "net/http";
+    "net/http/httptest";
+    "path";
+    "sort";
+    "strconv";
+    "strings";
+    "sync";
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Links in the fixtures point at the real API just like PokeAPI does, so clients have to rewrite them
+const upstreamBaseURL = "https://pokeapi.co/api/v2"
+
+type Server struct {
+    *httptest.Server
+    mu          sync.Mutex
+    // resource kind, e.g. "pokemon", to name to raw JSON
+    resources   map[string]map[string][]byte
+    ids         map[string]map[string]string
+    requests    map[string]int
+    statuses    map[string]int
+}
+
+//Starts a server loaded with the embedded fixtures. Close it when done
+func NewServer() *Server {
+    s := &Server{
+        resources:  make(map[string]map[string][]byte),
+        ids:        make(map[string]map[string]string),
+        requests:   make(map[string]int),
+        statuses:   make(map[string]int),
+    }
+    err := fs.WalkDir(fixtures, "fixtures", func(p string, d fs.DirEntry, err error) error {
+        if err != nil || d.IsDir() {
+            return err
+        }
+        data, err := fixtures.ReadFile(p)
+        if err != nil {
+            return err
+        }
+        kind := path.Base(path.Dir(p))
+        return s.Add(kind, strings.TrimSuffix(path.Base(p), ".json"), data)
+    })
+    if err != nil {
+        panic(fmt.Sprintf("fakeapi: broken fixtures: %v", err))
+    }
+    s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+    return s
+}
+
+//Base URL to hand to Client.SetBaseURL
+func (s *Server) BaseURL() string {
+    return s.URL + "/api/v2"
+}
+
+//Serves data for kind/name and kind/<id> where the id is read from the payload
+func (s *Server) Add(kind string, name string, data []byte) error {
+    var head struct {
+        ID int `json:"id"`
+    }
+    if err := json.Unmarshal(data, &head); err != nil {
+        return fmt.Errorf("Fixture %v/%v is not valid JSON: %v", kind, name, err)
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.resources[kind] == nil {
+        s.resources[kind] = make(map[string][]byte)
+        s.ids[kind] = make(map[string]string)
+    }
+    s.resources[kind][name] = data
+    s.ids[kind][strconv.Itoa(head.ID)] = name
+    return nil
+}
+
+//Makes every request for the path, e.g. /api/v2/pokemon/pikachu, answer with status. 0 goes back to normal
+func (s *Server) SetStatus(urlPath string, status int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if status == 0 {
+        delete(s.statuses, urlPath)
+        return
+    }
+    s.statuses[urlPath] = status
+}
+
+//Number of requests received for the path, handy to tell cache hits from network round trips
+func (s *Server) Requests(urlPath string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.requests[urlPath]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+    urlPath := strings.TrimSuffix(r.URL.Path, "/")
+    s.mu.Lock()
+    s.requests[urlPath]++
+    status := s.statuses[urlPath]
+    s.mu.Unlock()
+    if status != 0 {
+        http.Error(w, http.StatusText(status), status)
+        return
+    }
+    rest, ok := strings.CutPrefix(urlPath, "/api/v2/")
+    if !ok {
+        http.NotFound(w, r)
+        return
+    }
+    kind, name, _ := strings.Cut(rest, "/")
+    if name == "" {
+        s.handleList(w, r, kind)
+        return
+    }
+    s.mu.Lock()
+    if byID, ok := s.ids[kind][name]; ok {
+        name = byID
+    }
+    data, ok := s.resources[kind][name]
+    s.mu.Unlock()
+    if !ok {
+        http.NotFound(w, r)
+        return
+    }
+    // Lets clients revalidate with If-None-Match like they would against the real API
+    etag := fmt.Sprintf("\"%x\"", sha256.Sum256(data))
+    w.Header().Set("ETag", etag)
+    if r.Header.Get("If-None-Match") == etag {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(data)
+}
+
+type namedResource struct {
+    Name    string `json:"name"`
+    URL     string `json:"url"`
+}
+
+type listPage struct {
+    Count       int             `json:"count"`
+    Next        *string         `json:"next"`
+    Previous    *string         `json:"previous"`
+    Results     []namedResource `json:"results"`
+}
+
+//Paginates a kind like PokeAPI does, ordered by id and honouring offset and limit
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, kind string) {
+    offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+    limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+    if err != nil || limit <= 0 {
+        limit = 20
+    }
+    s.mu.Lock()
+    type entry struct {
+        id      int
+        name    string
+    }
+    var entries []entry
+    for id, name := range s.ids[kind] {
+        n, _ := strconv.Atoi(id)
+        entries = append(entries, entry{id: n, name: name})
+    }
+    s.mu.Unlock()
+    if entries == nil {
+        http.NotFound(w, r)
+        return
+    }
+    sort.Slice(entries, func(i, j int) bool {
+        return entries[i].id < entries[j].id
+    })
+    page := listPage{
+        Count:      len(entries),
+        Results:    []namedResource{},
+    }
+    for i := offset; i < offset+limit && i < len(entries); i++ {
+        page.Results = append(page.Results, namedResource{
+            Name:   entries[i].name,
+            URL:    fmt.Sprintf("%v/%v/%d/", upstreamBaseURL, kind, entries[i].id),
+        })
+    }
+    if offset+limit < len(entries) {
+        next := fmt.Sprintf("%v/%v?offset=%d&limit=%d", upstreamBaseURL, kind, offset+limit, limit)
+        page.Next = &next
+    }
+    if offset > 0 {
+        previous := fmt.Sprintf("%v/%v?offset=%d&limit=%d", upstreamBaseURL, kind, max(0, offset-limit), limit)
+        page.Previous = &previous
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,372 @@
+package internal
+
+import (
+    "context";
+    "encoding/json";
+    "errors";
+    "fmt";
+    "io";
+    "net/http";
+    "net/url";
+    "strconv";
+    "strings";
+    "time";
+)
+
+const (
+    defaultBaseURL = "https://pokeapi.co/api/v2"
+    apiPathPrefix = "/api/v2/"
+    LocationAreaPageSize = 20
+)
+
+// Matches any StatusError for a 404 or NotFoundError via errors.Is
+var ErrNotFound = errors.New("not found")
+
+// Error with its own message for something that does not exist, matches ErrNotFound
+type NotFoundError struct {
+    Msg string
+}
+
+func (e *NotFoundError) Error() string {
+    return e.Msg
+}
+
+func (e *NotFoundError) Is(target error) bool {
+    return target == ErrNotFound
+}
+
+// Matches any OfflineError via errors.Is
+var ErrOffline = errors.New("not available offline")
+
+// Returned in offline mode for anything the cache cannot answer
+type OfflineError struct {
+    // Path relative to the base URL, e.g. pokemon/pikachu
+    Resource string
+}
+
+func (e *OfflineError) Error() string {
+    return fmt.Sprintf("%v is not available offline", e.Resource)
+}
+
+func (e *OfflineError) Is(target error) bool {
+    return target == ErrOffline
+}
+
+// Returned when PokeAPI answers with a status outside of 2xx
+type StatusError struct {
+    URL         string
+    StatusCode  int
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("Request to %v failed with status code: %d", e.URL, e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+    return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Typed access to PokeAPI. Every response goes through the Cache, keyed by its URL
+type Client struct {
+    httpClient  *http.Client
+    cache       *Cache
+    baseURL     string
+    offline     bool
+}
+
+func NewClient(cache *Cache) *Client {
+    return &Client{
+        httpClient: http.DefaultClient,
+        cache:      cache,
+        baseURL:    defaultBaseURL,
+    }
+}
+
+//Sends all requests through rt, e.g. a RetryTransport
+func (c *Client) SetTransport(rt http.RoundTripper) {
+    c.httpClient = &http.Client{Transport: rt}
+}
+
+//In offline mode every answer comes from the cache, expired entries included, and nothing goes over the network
+func (c *Client) SetOffline(offline bool) {
+    c.offline = offline
+}
+
+func (c *Client) Offline() bool {
+    return c.offline
+}
+
+//Names of the resources of kind, e.g. "pokemon", that the cache can answer for. Ids are left out since anything
+//fetched by id is cached under its name as well
+func (c *Client) CachedNames(kind string) []string {
+    prefix := c.baseURL + "/" + kind + "/"
+    var names []string
+    for _, key := range c.cache.Keys() {
+        name, ok := strings.CutPrefix(key, prefix)
+        if !ok || name == "" || strings.ContainsAny(name, "/?") {
+            continue
+        }
+        if _, err := strconv.Atoi(name); err == nil {
+            continue
+        }
+        if unescaped, err := url.PathUnescape(name); err == nil {
+            name = unescaped
+        }
+        names = append(names, name)
+    }
+    return names
+}
+
+//Points the client at another PokeAPI deployment such as a self-hosted mirror, e.g. http://localhost:8000/api/v2
+func (c *Client) SetBaseURL(raw string) error {
+    parsed, err := url.Parse(raw)
+    if err != nil {
+        return fmt.Errorf("Invalid base URL %v with error: %v", raw, err)
+    }
+    if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+        return fmt.Errorf("Invalid base URL %v, expected an absolute http(s) URL", raw)
+    }
+    c.baseURL = strings.TrimRight(raw, "/")
+    return nil
+}
+
+func (c *Client) BaseURL() string {
+    return c.baseURL
+}
+
+//PokeAPI returns absolute links built from its own host, this maps them onto the configured base URL
+func (c *Client) rewriteURL(link string) string {
+    if link == "" {
+        return link
+    }
+    parsed, err := url.Parse(link)
+    if err != nil {
+        return link
+    }
+    i := strings.Index(parsed.Path, apiPathPrefix)
+    if i < 0 {
+        return link
+    }
+    rewritten := c.baseURL + "/" + parsed.Path[i+len(apiPathPrefix):]
+    if parsed.RawQuery != "" {
+        rewritten += "?" + parsed.RawQuery
+    }
+    return rewritten
+}
+
+//Returns the given 1-based page of location areas, LocationAreaPageSize per page
+func (c *Client) ListLocationAreas(ctx context.Context, page int) (LocationAreaList, error) {
+    if page < 1 {
+        return LocationAreaList{}, fmt.Errorf("Invalid page %v, pages start at 1", page)
+    }
+    offset := (page - 1) * LocationAreaPageSize
+    pageURL := c.baseURL + "/location-area?offset=" + strconv.Itoa(offset) + "&limit=" + strconv.Itoa(LocationAreaPageSize)
+    var list LocationAreaList
+    if err := c.getJSON(ctx, pageURL, &list); err != nil {
+        return LocationAreaList{}, err
+    }
+    list.Next = c.rewriteURL(list.Next)
+    list.Previous = c.rewriteURL(list.Previous)
+    for i := range list.Results {
+        list.Results[i].URL = c.rewriteURL(list.Results[i].URL)
+    }
+    return list, nil
+}
+
+//Looks an area up by name or id. Areas fetched by id are cached under their name too
+func (c *Client) GetLocationArea(ctx context.Context, name string) (LocationArea, error) {
+    var area LocationArea
+    if err := c.getJSON(ctx, c.baseURL+"/location-area/"+url.PathEscape(name), &area); err != nil {
+        return LocationArea{}, err
+    }
+    c.alias("location-area/"+url.PathEscape(name), "location-area/"+url.PathEscape(area.Name))
+    return area, nil
+}
+
+//Looks a Pokemon up by name or id. Pokemon fetched by id are cached under their name too
+func (c *Client) GetPokemon(ctx context.Context, name string) (Pokemon, error) {
+    var pokemon Pokemon
+    if err := c.getJSON(ctx, c.baseURL+"/pokemon/"+url.PathEscape(name), &pokemon); err != nil {
+        return Pokemon{}, err
+    }
+    c.alias("pokemon/"+url.PathEscape(name), "pokemon/"+url.PathEscape(pokemon.Name))
+    return pokemon, nil
+}
+
+//Returns the raw body for path relative to the base URL, mostly useful for debugging
+func (c *Client) GetRaw(ctx context.Context, path string) ([]byte, error) {
+    return c.get(ctx, c.baseURL + path)
+}
+
+func (c *Client) getJSON(ctx context.Context, link string, v any) error {
+    body, err := c.get(ctx, link)
+    if err != nil {
+        return err
+    }
+    return decodeJSON(link, body, v)
+}
+
+func decodeJSON(link string, body []byte, v any) error {
+    if err := json.Unmarshal(body, v); err != nil {
+        return fmt.Errorf("Failed to unmarshal response from %v with error: %w", link, err)
+    }
+    return nil
+}
+
+//Caches the entry for path under a second path, both relative to the base URL
+func (c *Client) alias(path string, aliasPath string) {
+    if path == aliasPath {
+        return
+    }
+    if cached, ok := c.cache.peek(c.baseURL + "/" + path); ok {
+        c.cache.Put(c.baseURL+"/"+aliasPath, cached.Val, cached.Validators)
+    }
+}
+
+func (c *Client) get(ctx context.Context, link string) ([]byte, error) {
+    if c.offline {
+        if val, ok := c.cache.GetStale(link); ok {
+            return val, nil
+        }
+        return nil, &OfflineError{Resource: strings.TrimPrefix(link, c.baseURL+"/")}
+    }
+    return c.cache.GetOrFetch(ctx, link, func(stale *CacheLookup) ([]byte, Validators, error) {
+        return c.fetch(ctx, link, stale)
+    })
+}
+
+//Downloads link, sending the validators of stale along so the server can answer 304 Not Modified
+func (c *Client) fetch(ctx context.Context, link string, stale *CacheLookup) ([]byte, Validators, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+    if err != nil {
+        return nil, Validators{}, fmt.Errorf("Failed to build request for %v with error: %w", link, err)
+    }
+    if stale != nil {
+        if stale.Validators.ETag != "" {
+            req.Header.Set("If-None-Match", stale.Validators.ETag)
+        }
+        if stale.Validators.LastModified != "" {
+            req.Header.Set("If-Modified-Since", stale.Validators.LastModified)
+        }
+    }
+    res, err := c.httpClient.Do(req)
+    if err != nil {
+        return nil, Validators{}, fmt.Errorf("Request failed with error: %w", err)
+    }
+    defer res.Body.Close()
+    if res.StatusCode == http.StatusNotModified && stale != nil {
+        return nil, validatorsFrom(res.Header), ErrNotModified
+    }
+    if res.StatusCode > 299 {
+        return nil, Validators{}, &StatusError{URL: link, StatusCode: res.StatusCode}
+    }
+    body, err := io.ReadAll(res.Body)
+    if err != nil {
+        return nil, Validators{}, fmt.Errorf("Failed to read response body with error: %w", err)
+    }
+    return body, validatorsFrom(res.Header), nil
+}
+
+//Reads ETag, Last-Modified and the max-age of Cache-Control from a response
+func validatorsFrom(header http.Header) Validators {
+    validators := Validators{
+        ETag:           header.Get("ETag"),
+        LastModified:   header.Get("Last-Modified"),
+    }
+    for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+        value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age=")
+        if !ok {
+            continue
+        }
+        if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+            validators.MaxAge = time.Duration(seconds) * time.Second
+        }
+    }
+    return validators
+}
+
+// One page of the location-area listing
+type LocationAreaList struct {
+    Count    int    `json:"count"`
+    Next     string `json:"next"`
+    Previous string `json:"previous"`
+    Results  []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"results"`
+}
+
+type LocationArea struct {
+    ID                   int    `json:"id"`
+    Name                 string `json:"name"`
+    GameIndex            int    `json:"game_index"`
+    EncounterMethodRates []struct {
+        EncounterMethod struct {
+            Name string `json:"name"`
+            URL  string `json:"url"`
+        } `json:"encounter_method"`
+        VersionDetails []struct {
+            Rate    int `json:"rate"`
+            Version struct {
+                Name string `json:"name"`
+                URL  string `json:"url"`
+            } `json:"version"`
+        } `json:"version_details"`
+    } `json:"encounter_method_rates"`
+    Location struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"location"`
+    Names []struct {
+        Name     string `json:"name"`
+        Language struct {
+            Name string `json:"name"`
+            URL  string `json:"url"`
+        } `json:"language"`
+    } `json:"names"`
+    PokemonEncounters []struct {
+        Pokemon struct {
+            Name string `json:"name"`
+            URL  string `json:"url"`
+        } `json:"pokemon"`
+        VersionDetails []struct {
+            Version struct {
+                Name string `json:"name"`
+                URL  string `json:"url"`
+            } `json:"version"`
+            MaxChance        int `json:"max_chance"`
+            EncounterDetails []struct {
+                MinLevel        int   `json:"min_level"`
+                MaxLevel        int   `json:"max_level"`
+                ConditionValues []any `json:"condition_values"`
+                Chance          int   `json:"chance"`
+                Method          struct {
+                    Name string `json:"name"`
+                    URL  string `json:"url"`
+                } `json:"method"`
+            } `json:"encounter_details"`
+        } `json:"version_details"`
+    } `json:"pokemon_encounters"`
+}
+
+type Region struct {
+    ID          int    `json:"id"`
+    Name        string `json:"name"`
+    Locations   []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"locations"`
+    Pokedexes   []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"pokedexes"`
+}
+
+type Location struct {
+    ID      int    `json:"id"`
+    Name    string `json:"name"`
+    Areas   []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"areas"`
+}
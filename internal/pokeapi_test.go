@@ -0,0 +1,60 @@
+package internal
+
+import (
+    "context";
+    "net/http";
+    "net/http/httptest";
+    "sync";
+    "testing";
+    "time";
+)
+
+func TestExpiredEntryIsRevalidated(t *testing.T) {
+    var mu sync.Mutex
+    var statuses []int
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("ETag", `"v1"`)
+        status := http.StatusOK
+        if r.Header.Get("If-None-Match") == `"v1"` {
+            //The 304 extends the lifetime the first response did not give
+            w.Header().Set("Cache-Control", "max-age=3600")
+            status = http.StatusNotModified
+        }
+        mu.Lock()
+        statuses = append(statuses, status)
+        mu.Unlock()
+        w.WriteHeader(status)
+        if status == http.StatusOK {
+            w.Write([]byte("body"))
+        }
+    }))
+    defer server.Close()
+    clock := newFakeClock()
+    cache := NewCacheWithClock(60, clock)
+    defer cache.Close()
+    client := NewClient(cache)
+    if err := client.SetBaseURL(server.URL); err != nil {
+        t.Fatalf("SetBaseURL failed: %v", err)
+    }
+
+    for _, advance := range []time.Duration{0, 2 * time.Minute, 2 * time.Minute} {
+        clock.Advance(advance)
+        body, err := client.GetRaw(context.Background(), "/resource")
+        if err != nil {
+            t.Fatalf("GetRaw failed: %v", err)
+        }
+        if string(body) != "body" {
+            t.Fatalf("GetRaw = %q, want the cached body", body)
+        }
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    // The third call is within the max-age of the 304 and answered by the cache alone
+    if len(statuses) != 2 || statuses[0] != http.StatusOK || statuses[1] != http.StatusNotModified {
+        t.Fatalf("server answered %v, want 200 then 304", statuses)
+    }
+    cached, ok := cache.Lookup(server.URL + "/resource")
+    if !ok || cached.Validators.ETag != `"v1"` || cached.Validators.MaxAge != time.Hour {
+        t.Fatalf("Lookup = %+v, %v, want the ETag and the max-age of the 304", cached, ok)
+    }
+}
@@ -1,6 +1,11 @@
 package internal
 
 import (
+    "container/list";
+    "context";
+    "errors";
+    "sort";
+    "strings";
     "sync";
     "time";
     "fmt";
@@ -10,59 +15,517 @@ type Cache struct {
     Entries     map[string]cacheEntry
     mu          sync.Mutex
     interval    time.Duration 
+    disk        *diskStore
+    clock       Clock
+    done        chan struct{}
+    stopped     chan struct{}
+    closeOnce   sync.Once
+    // Front is the most recently used key
+    lru         *list.List
+    size        int64
+    maxBytes    int64
+    maxEntries  int
+    hits        int64
+    misses      int64
+    // In-flight GetOrFetch calls by key
+    flights     map[string]*flight
+}
+
+// A fetch started by GetOrFetch that later callers for the same key wait on
+type flight struct {
+    done    chan struct{}
+    val     []byte
+    err     error
+}
+
+// Returned by a FetchFunc when the stale entry it was handed is still valid, GetOrFetch then refreshes it and merges
+// the Validators returned along with it into the entry
+var ErrNotModified = errors.New("not modified")
+
+// Produces the value for a key GetOrFetch has no fresh entry for. stale is the expired entry if one is still around
+type FetchFunc func(stale *CacheLookup) ([]byte, Validators, error)
+
+// Snapshot of the Cache for reporting
+type CacheStats struct {
+    Entries     int
+    Bytes       int64
+    DiskEntries int
+    DiskBytes   int64
+    Hits        int64
+    Misses      int64
+    // Oldest entry in memory or on disk, empty if the Cache is empty
+    OldestKey   string
+    OldestAt    time.Time
 }
 
 type cacheEntry struct {
     createdAt   time.Time
     val         []byte
+    validators  Validators
+    elem        *list.Element
 }
 
-//Main function of this internal package for setting up a Cache 
+// HTTP validators of a cached response. Entries that have them outlive their expiry so they can be revalidated
+type Validators struct {
+    ETag            string
+    LastModified    string
+    // From Cache-Control, overrides the interval of the Cache when set
+    MaxAge          time.Duration
+}
+
+func (v Validators) revalidatable() bool {
+    return v.ETag != "" || v.LastModified != ""
+}
+
+//Replaces the fields of v that are set in newer
+func (v Validators) merge(newer Validators) Validators {
+    if newer.ETag != "" {
+        v.ETag = newer.ETag
+    }
+    if newer.LastModified != "" {
+        v.LastModified = newer.LastModified
+    }
+    if newer.MaxAge > 0 {
+        v.MaxAge = newer.MaxAge
+    }
+    return v
+}
+
+// Result of Lookup
+type CacheLookup struct {
+    Val         []byte
+    Validators  Validators
+    Fresh       bool
+}
+
+//Main function of this internal package for setting up a Cache. Starts the reaper, stop it again with Close
 func NewCache(interval int) *Cache {
-    return &Cache{
+    return NewCacheWithClock(interval, realClock{})
+}
+
+//Same as NewCache but reads the time and ticks from clock. An interval of 0 or less never expires entries
+func NewCacheWithClock(interval int, clock Clock) *Cache {
+    c := &Cache{
         Entries: make(map[string]cacheEntry),
         interval: time.Duration(interval) * time.Second,
+        clock: clock,
+        done: make(chan struct{}),
+        stopped: make(chan struct{}),
+        lru: list.New(),
+        flights: make(map[string]*flight),
+    }
+    if c.interval > 0 {
+        go c.reapLoop(clock.NewTicker(c.interval))
+    } else {
+        close(c.stopped)
+    }
+    return c
+}
+
+//Stops the reaper and waits for it to return. Safe to call more than once
+func (c *Cache) Close() error {
+    c.closeOnce.Do(func() {
+        close(c.done)
+    })
+    <-c.stopped
+    return nil
+}
+
+//Enables the on-disk tier so entries survive restarts. maxBytes bounds the size of dir, 0 means unbounded
+func (c *Cache) Persist(dir string, maxBytes int64) error {
+    disk, err := newDiskStore(dir, maxBytes)
+    if err != nil {
+        return err
     }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.disk = disk
+    return nil
+}
+
+//Bounds the in-memory entries by total value size and count, evicting the least recently used first. 0 means unbounded
+func (c *Cache) SetLimits(maxBytes int64, maxEntries int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.maxBytes = maxBytes
+    c.maxEntries = maxEntries
+    c.evict()
 }
 
 //frucntion to add new entries to the map
 func (c *Cache) Add(key string, val []byte) error {
     c.mu.Lock()
-    defer c.mu.Unlock()
     if _, ok := c.Entries[key]; ok {
+        c.mu.Unlock()
         return fmt.Errorf("The key %v already exists in the Cache.", key)
     }
+    entry, disk := c.put(key, val, Validators{})
+    c.mu.Unlock()
+    return storeOnDisk(disk, key, entry)
+}
+
+//Adds or replaces the entry for key along with the validators of the response it came from
+func (c *Cache) Put(key string, val []byte, validators Validators) error {
+    c.mu.Lock()
+    entry, disk := c.put(key, val, validators)
+    c.mu.Unlock()
+    return storeOnDisk(disk, key, entry)
+}
+
+//Caller must hold c.mu. Stores the entry in memory, the caller writes it to the returned disk tier after unlocking
+func (c *Cache) put(key string, val []byte, validators Validators) (cacheEntry, *diskStore) {
     entry := cacheEntry{
-        createdAt:  time.Now(),
+        createdAt:  c.clock.Now(),
         val:        val,
+        validators: validators,
+    }
+    c.insert(key, entry)
+    return entry, c.disk
+}
+
+//Writes entry to disk if there is a disk tier. Call without holding c.mu, disk I/O must not block the other callers
+func storeOnDisk(disk *diskStore, key string, entry cacheEntry) error {
+    if disk == nil {
+        return nil
+    }
+    return disk.put(key, entry)
+}
+
+//Reads key from disk if there is a disk tier. Call without holding c.mu
+func loadFromDisk(disk *diskStore, key string) (cacheEntry, bool) {
+    if disk == nil {
+        return cacheEntry{}, false
+    }
+    return disk.get(key)
+}
+
+//Returns the in-memory entry for key and the disk tier to look in if there is none
+func (c *Cache) fromMemory(key string) (cacheEntry, bool, *diskStore) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    entry, ok := c.Entries[key]
+    if ok {
+        c.lru.MoveToFront(entry.elem)
+    }
+    return entry, ok, c.disk
+}
+
+//Returns the entry for key even if it expired, as long as it is still around. Fresh tells whether Get would return it
+func (c *Cache) Lookup(key string) (CacheLookup, bool) {
+    entry, ok, disk := c.fromMemory(key)
+    if !ok {
+        entry, ok = loadFromDisk(disk, key)
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if !ok {
+        c.count(false)
+        return CacheLookup{}, false
+    }
+    fresh := !c.expired(entry)
+    c.count(fresh)
+    if fresh {
+        if _, inMemory := c.Entries[key]; !inMemory {
+            c.insert(key, entry)
+        }
+    }
+    return CacheLookup{
+        Val:        entry.val,
+        Validators: entry.validators,
+        Fresh:      fresh,
+    }, true
+}
+
+//Same as Lookup but leaves the hit and miss counters and the LRU order alone, for the client's own bookkeeping
+//such as aliasing or checking what is cached, which are no lookups on behalf of a command
+func (c *Cache) peek(key string) (CacheLookup, bool) {
+    c.mu.Lock()
+    entry, ok := c.Entries[key]
+    disk := c.disk
+    c.mu.Unlock()
+    if !ok {
+        entry, ok = loadFromDisk(disk, key)
+    }
+    if !ok {
+        return CacheLookup{}, false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return CacheLookup{
+        Val:        entry.val,
+        Validators: entry.validators,
+        Fresh:      !c.expired(entry),
+    }, true
+}
+
+//Returns the fresh entry for key or calls fetch for a new one and stores it. Concurrent calls for the same key
+//share a single fetch and its result. Waiting for another caller's fetch stops when ctx is done, and if that fetch
+//was cancelled by its own caller while ctx is still live, this call fetches again itself
+func (c *Cache) GetOrFetch(ctx context.Context, key string, fetch FetchFunc) ([]byte, error) {
+    for {
+        cached, found := c.Lookup(key)
+        if found && cached.Fresh {
+            return cached.Val, nil
+        }
+        c.mu.Lock()
+        f, waiting := c.flights[key]
+        if !waiting {
+            f = &flight{done: make(chan struct{})}
+            c.flights[key] = f
+        }
+        c.mu.Unlock()
+        if !waiting {
+            var stale *CacheLookup
+            if found {
+                stale = &cached
+            }
+            return c.lead(key, f, stale, fetch)
+        }
+        select {
+        case <-f.done:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+        if isContextError(f.err) && ctx.Err() == nil {
+            continue
+        }
+        return f.val, f.err
     }
-    c.Entries[key] = entry
-    return nil 
+}
+
+//Runs the fetch of flight f and hands its result to everyone waiting on it
+func (c *Cache) lead(key string, f *flight, stale *CacheLookup, fetch FetchFunc) ([]byte, error) {
+    defer func() {
+        c.mu.Lock()
+        delete(c.flights, key)
+        c.mu.Unlock()
+        close(f.done)
+    }()
+    val, validators, err := fetch(stale)
+    switch {
+    case errors.Is(err, ErrNotModified) && stale != nil:
+        c.Touch(key, validators)
+        f.val = stale.Val
+    case err != nil:
+        f.err = err
+    default:
+        // The fetched value is good even if the disk tier fails to store it
+        c.Put(key, val, validators)
+        f.val = val
+    }
+    return f.val, f.err
+}
+
+func isContextError(err error) bool {
+    return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+//Marks the entry for key as fresh again, e.g. after the server answered 304 Not Modified. The fields set in
+//validators replace those of the entry, like the headers of a 304 update the stored response. Reports whether key exists
+func (c *Cache) Touch(key string, validators Validators) bool {
+    entry, ok, disk := c.fromMemory(key)
+    if !ok {
+        entry, ok = loadFromDisk(disk, key)
+    }
+    if !ok {
+        return false
+    }
+    c.mu.Lock()
+    entry.createdAt = c.clock.Now()
+    entry.validators = entry.validators.merge(validators)
+    c.insert(key, entry)
+    c.mu.Unlock()
+    storeOnDisk(disk, key, entry)
+    return true
 }
 
 //Function to retrieve a Cache entry 
 func (c *Cache) Get(key string) ([]byte, bool) {
-     
+    entry, inMemory, disk := c.fromMemory(key)
+    ok := inMemory
+    if !ok {
+        entry, ok = loadFromDisk(disk, key)
+    }
     c.mu.Lock()
     defer c.mu.Unlock()
-    entry, ok := c.Entries[key]
+    // Only entries with validators are kept past their expiry in memory, they wait for revalidation through Lookup.
+    //Expired files stay on disk for GetStale until the size bound of the disk tier pushes them out
+    if !ok || c.expired(entry) {
+        c.count(false)
+        return nil, false
+    }
+    //Promotes fresh entries found on disk back into memory
+    if _, ok := c.Entries[key]; !inMemory && !ok {
+        c.insert(key, entry)
+    }
+    c.count(true)
+    return entry.val, true
+}
+
+//Caller must hold c.mu
+func (c *Cache) count(hit bool) {
+    if hit {
+        c.hits++
+    } else {
+        c.misses++
+    }
+}
+
+//Same as Get but also returns expired entries the disk tier still holds, e.g. to answer without network access
+func (c *Cache) GetStale(key string) ([]byte, bool) {
+    entry, ok, disk := c.fromMemory(key)
+    if !ok {
+        entry, ok = loadFromDisk(disk, key)
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.count(ok)
     if !ok {
         return nil, false
     }
     return entry.val, true
 }
+
+func (c *Cache) Stats() CacheStats {
+    c.mu.Lock()
+    stats := CacheStats{
+        Entries:    len(c.Entries),
+        Bytes:      c.size,
+        Hits:       c.hits,
+        Misses:     c.misses,
+    }
+    consider := func(key string, createdAt time.Time) {
+        if stats.OldestKey == "" || createdAt.Before(stats.OldestAt) {
+            stats.OldestKey = key
+            stats.OldestAt = createdAt
+        }
+    }
+    for key, entry := range c.Entries {
+        consider(key, entry.createdAt)
+    }
+    disk := c.disk
+    c.mu.Unlock()
+    if disk != nil {
+        for _, info := range disk.entries() {
+            stats.DiskEntries++
+            stats.DiskBytes += info.size
+            consider(info.key, info.createdAt)
+        }
+    }
+    return stats
+}
+
+//Removes key from memory and disk, reports whether it was there
+func (c *Cache) Delete(key string) bool {
+    c.mu.Lock()
+    _, found := c.Entries[key]
+    c.remove(key)
+    disk := c.disk
+    c.mu.Unlock()
+    if disk != nil && disk.remove(key) {
+        found = true
+    }
+    return found
+}
+
+//Removes every key starting with prefix from memory and disk and returns how many went
+func (c *Cache) DeletePrefix(prefix string) int {
+    deleted := 0
+    for _, key := range c.Keys() {
+        if strings.HasPrefix(key, prefix) && c.Delete(key) {
+            deleted++
+        }
+    }
+    return deleted
+}
+
+//Removes everything from memory and disk and returns how many entries went
+func (c *Cache) Clear() int {
+    return c.DeletePrefix("")
+}
+
+//Keys of all entries in memory and on disk, expired ones on disk included
+func (c *Cache) Keys() []string {
+    c.mu.Lock()
+    seen := make(map[string]bool, len(c.Entries))
+    keys := make([]string, 0, len(c.Entries))
+    for key := range c.Entries {
+        seen[key] = true
+        keys = append(keys, key)
+    }
+    disk := c.disk
+    c.mu.Unlock()
+    if disk != nil {
+        for _, info := range disk.entries() {
+            if !seen[info.key] {
+                seen[info.key] = true
+                keys = append(keys, info.key)
+            }
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+//Caller must hold c.mu. Replaces an existing entry for key. A value larger than the whole byte budget is not kept
+//in memory at all, evicting everything else for it would only empty the Cache. The disk tier still holds it
+func (c *Cache) insert(key string, entry cacheEntry) {
+    c.remove(key)
+    if c.maxBytes > 0 && int64(len(entry.val)) > c.maxBytes {
+        return
+    }
+    entry.elem = c.lru.PushFront(key)
+    c.Entries[key] = entry
+    c.size += int64(len(entry.val))
+    c.evict()
+}
+
+//Caller must hold c.mu. Only drops the in-memory entry, the disk tier keeps its copy
+func (c *Cache) remove(key string) {
+    entry, ok := c.Entries[key]
+    if !ok {
+        return
+    }
+    c.lru.Remove(entry.elem)
+    delete(c.Entries, key)
+    c.size -= int64(len(entry.val))
+}
+
+//Caller must hold c.mu
+func (c *Cache) evict() {
+    for c.lru.Len() > 0 && ((c.maxEntries > 0 && len(c.Entries) > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes)) {
+        c.remove(c.lru.Back().Value.(string))
+    }
+}
  
+func (c *Cache) expired(entry cacheEntry) bool {
+    ttl := c.interval
+    if entry.validators.MaxAge > 0 {
+        ttl = entry.validators.MaxAge
+    }
+    return ttl > 0 && c.clock.Now().Sub(entry.createdAt) > ttl
+}
+
 //Function to clean up entries after a certain duration specified in the NewCache function 
-func (c *Cache) reapLoop() {
-    ticker := time.NewTicker(c.interval)
+func (c *Cache) reapLoop(ticker Ticker) {
+    defer close(c.stopped)
+    defer ticker.Stop()
     for {
-        <-ticker.C 
-        c.mu.Lock()
-        for key, entry := range c.Entries {
-            if time.Since(entry.createdAt) > c.interval {
-                delete(c.Entries, key)
-            }
+        select {
+        case <-c.done:
+            return
+        case <-ticker.C():
+            c.reap()
+        }
+    }
+}
+
+func (c *Cache) reap() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for key, entry := range c.Entries {
+        if c.expired(entry) && !entry.validators.revalidatable() {
+            c.remove(key)
         }
-    c.mu.Unlock()
     }
 }
@@ -0,0 +1,248 @@
+package internal
+
+import (
+    "context";
+    "errors";
+    "fmt";
+    "sync";
+    "testing";
+    "time";
+)
+
+// Clock that only moves when the test says so
+type fakeClock struct {
+    mu      sync.Mutex
+    now     time.Time
+    ticker  *fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+    return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.ticker = &fakeTicker{c: make(chan time.Time), stopped: make(chan struct{})}
+    return c.ticker
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.now = c.now.Add(d)
+}
+
+//Sends a tick and waits until the reaper took it. The channel is unbuffered, so the second send only goes through
+//once the reap for the first one is done
+func (c *fakeClock) Tick(t *testing.T) {
+    t.Helper()
+    for i := 0; i < 2; i++ {
+        select {
+        case c.ticker.c <- c.Now():
+        case <-time.After(time.Second):
+            t.Fatal("reaper did not take the tick")
+        }
+    }
+}
+
+type fakeTicker struct {
+    c           chan time.Time
+    stopped     chan struct{}
+    stopOnce    sync.Once
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+    return t.c
+}
+
+func (t *fakeTicker) Stop() {
+    t.stopOnce.Do(func() {
+        close(t.stopped)
+    })
+}
+
+func TestEntriesExpireAfterInterval(t *testing.T) {
+    clock := newFakeClock()
+    c := NewCacheWithClock(5, clock)
+    defer c.Close()
+
+    if err := c.Add("key", []byte("val")); err != nil {
+        t.Fatalf("Add failed: %v", err)
+    }
+    clock.Advance(4 * time.Second)
+    clock.Tick(t)
+    if val, ok := c.Get("key"); !ok || string(val) != "val" {
+        t.Fatalf("Get before the interval = %q, %v, want \"val\", true", val, ok)
+    }
+
+    clock.Advance(2 * time.Second)
+    clock.Tick(t)
+    if _, ok := c.Get("key"); ok {
+        t.Fatal("Get after the interval found the entry")
+    }
+    c.mu.Lock()
+    remaining := len(c.Entries)
+    c.mu.Unlock()
+    if remaining != 0 {
+        t.Fatalf("reaper left %d entries behind", remaining)
+    }
+}
+
+func TestCloseStopsReaper(t *testing.T) {
+    clock := newFakeClock()
+    c := NewCacheWithClock(5, clock)
+
+    if err := c.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    if err := c.Close(); err != nil {
+        t.Fatalf("second Close failed: %v", err)
+    }
+    select {
+    case <-c.stopped:
+    default:
+        t.Fatal("reaper goroutine is still running after Close")
+    }
+    select {
+    case <-clock.ticker.stopped:
+    default:
+        t.Fatal("ticker was not stopped")
+    }
+}
+
+func TestCloseWithoutReaper(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    if err := c.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+}
+
+func TestOversizedValueKeepsOtherEntries(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    c.SetLimits(3, 0)
+
+    c.Put("small", []byte("ab"), Validators{})
+    c.Put("big", []byte("abcd"), Validators{})
+    if _, ok := c.Get("small"); !ok {
+        t.Fatal("adding an oversized value evicted the other entries")
+    }
+    if _, ok := c.Get("big"); ok {
+        t.Fatal("oversized value was kept in memory")
+    }
+    if stats := c.Stats(); stats.Bytes != 2 {
+        t.Fatalf("Bytes = %d, want 2", stats.Bytes)
+    }
+}
+
+func TestOversizedValueStaysOnDisk(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    if err := c.Persist(t.TempDir(), 0); err != nil {
+        t.Fatalf("Persist failed: %v", err)
+    }
+    c.SetLimits(3, 0)
+
+    c.Put("big", []byte("abcd"), Validators{})
+    if val, ok := c.Get("big"); !ok || string(val) != "abcd" {
+        t.Fatalf("Get = %q, %v, want the value from disk", val, ok)
+    }
+}
+
+//Starts a GetOrFetch for key whose fetch blocks until release is closed, then returns result and err
+func startBlockedFetch(t *testing.T, c *Cache, key string, result []byte, err error) (release chan struct{}, done chan error) {
+    t.Helper()
+    started := make(chan struct{})
+    release = make(chan struct{})
+    done = make(chan error, 1)
+    go func() {
+        _, fetchErr := c.GetOrFetch(context.Background(), key, func(*CacheLookup) ([]byte, Validators, error) {
+            close(started)
+            <-release
+            return result, Validators{}, err
+        })
+        done <- fetchErr
+    }()
+    <-started
+    return release, done
+}
+
+func TestGetOrFetchSharesOneFetch(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    release, leaderDone := startBlockedFetch(t, c, "key", []byte("val"), nil)
+
+    waiterDone := make(chan []byte, 1)
+    go func() {
+        val, _ := c.GetOrFetch(context.Background(), "key", func(*CacheLookup) ([]byte, Validators, error) {
+            t.Error("second caller fetched instead of waiting")
+            return nil, Validators{}, nil
+        })
+        waiterDone <- val
+    }()
+    close(release)
+    if err := <-leaderDone; err != nil {
+        t.Fatalf("leader failed: %v", err)
+    }
+    if val := <-waiterDone; string(val) != "val" {
+        t.Fatalf("waiter got %q, want \"val\"", val)
+    }
+}
+
+func TestGetOrFetchWaiterHonoursItsContext(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    release, _ := startBlockedFetch(t, c, "key", []byte("val"), nil)
+    defer close(release)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    waiterDone := make(chan error, 1)
+    go func() {
+        _, err := c.GetOrFetch(ctx, "key", func(*CacheLookup) ([]byte, Validators, error) {
+            return nil, Validators{}, errors.New("second caller fetched instead of waiting")
+        })
+        waiterDone <- err
+    }()
+    cancel()
+    select {
+    case err := <-waiterDone:
+        if !errors.Is(err, context.Canceled) {
+            t.Fatalf("waiter returned %v, want context.Canceled", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("cancelled waiter is still blocked on the other fetch")
+    }
+}
+
+func TestGetOrFetchRetriesAfterLeaderWasCancelled(t *testing.T) {
+    c := NewCacheWithClock(0, newFakeClock())
+    defer c.Close()
+    release, leaderDone := startBlockedFetch(t, c, "key", nil, fmt.Errorf("Request failed with error: %w", context.Canceled))
+
+    waiterDone := make(chan []byte, 1)
+    go func() {
+        val, err := c.GetOrFetch(context.Background(), "key", func(*CacheLookup) ([]byte, Validators, error) {
+            return []byte("val"), Validators{}, nil
+        })
+        if err != nil {
+            t.Errorf("waiter failed: %v", err)
+        }
+        waiterDone <- val
+    }()
+    // Give the waiter a moment to join the flight before the leader gives up
+    time.Sleep(10 * time.Millisecond)
+    close(release)
+    if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+        t.Fatalf("leader returned %v, want context.Canceled", err)
+    }
+    if val := <-waiterDone; string(val) != "val" {
+        t.Fatalf("waiter got %q, want \"val\" from its own fetch", val)
+    }
+}
@@ -1,13 +1,61 @@
 package internal
 
 import (
+    "encoding/json";
+    "errors";
     "fmt";
+    "io/fs";
+    "os";
+    "sort";
+    "strconv";
+    "strings";
     "sync";
+    "time";
 )
 
+// Bump whenever the layout of saveFile changes and teach LoadPokedex to migrate the old one
+const saveFileVersion = 2
+
 type Pokedex struct {
     mu sync.Mutex
+    // Species level dex, every species caught at least once keyed by name
     Entries map[string]Pokemon 
+    // Species encountered so far, caught or not, with the time of the first encounter
+    Seen map[string]time.Time
+    // The individual Pokemon the player owns, keyed by their ID
+    Box map[int]CaughtPokemon
+    nextID int
+    path string
+}
+
+// One individual Pokemon the player caught
+type CaughtPokemon struct {
+    ID          int         `json:"id"`
+    Species     string      `json:"species"`
+    Nickname    string      `json:"nickname,omitempty"`
+    Level       int         `json:"level"`
+    CaughtAt    time.Time   `json:"caught_at"`
+    Location    string      `json:"location"`
+    // How it was encountered and the level range it could have had, as listed for the location area
+    Method      string      `json:"method,omitempty"`
+    MinLevel    int         `json:"min_level,omitempty"`
+    MaxLevel    int         `json:"max_level,omitempty"`
+}
+
+//Nickname if one was given, the species name otherwise
+func (c CaughtPokemon) DisplayName() string {
+    if c.Nickname != "" {
+        return c.Nickname
+    }
+    return c.Species
+}
+
+type saveFile struct {
+    Version int                     `json:"version"`
+    Entries map[string]Pokemon      `json:"entries"`
+    Seen    map[string]time.Time    `json:"seen"`
+    Box     []CaughtPokemon         `json:"box"`
+    NextID  int                     `json:"next_id"`
 }
 
 type Pokemon struct {
@@ -282,41 +330,322 @@ type Pokemon struct {
     } `json:"past_types"`
 }
 
+//Name of the species the pokemon is a form of, e.g. deoxys for deoxys-normal
+func (p Pokemon) SpeciesName() string {
+    if p.Species.Name != "" {
+        return p.Species.Name
+    }
+    return p.Name
+}
+
 func NewPokedex () *Pokedex {
     return &Pokedex {
         Entries: make(map[string]Pokemon),
+        Seen: make(map[string]time.Time),
+        Box: make(map[int]CaughtPokemon),
+        nextID: 1,
+    }
+}
+
+//Loads the Pokedex from the save file at path. A missing file gives an empty Pokedex that will be saved to path
+func LoadPokedex(path string) (*Pokedex, error) {
+    p := NewPokedex()
+    p.path = path
+    data, err := os.ReadFile(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return p, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("Failed to read save file %v with error: %v", path, err)
+    }
+    var save saveFile
+    if err := json.Unmarshal(data, &save); err != nil {
+        return nil, fmt.Errorf("Failed to unmarshal save file %v with error: %v", path, err)
+    }
+    switch save.Version {
+    case 1:
+        migrateSaveFileV1(&save)
+    case saveFileVersion:
+    default:
+        return nil, fmt.Errorf("Save file %v has unsupported version %v", path, save.Version)
+    }
+    if save.Entries != nil {
+        p.Entries = save.Entries
+    }
+    for species, at := range save.Seen {
+        p.Seen[species] = at
+    }
+    for _, caught := range save.Box {
+        p.Box[caught.ID] = caught
+        p.nextID = max(p.nextID, caught.ID+1)
     }
+    p.nextID = max(p.nextID, save.NextID)
+    return p, nil
 }
 
-func (p *Pokedex) Add(val Pokemon) error {
+//Version 1 only knew species, every one of them becomes a single individual of unknown level and origin
+func migrateSaveFileV1(save *saveFile) {
+    names := make([]string, 0, len(save.Entries))
+    for name := range save.Entries {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    save.Seen = make(map[string]time.Time)
+    for i, name := range names {
+        save.Seen[name] = time.Time{}
+        save.Box = append(save.Box, CaughtPokemon{
+            ID:         i + 1,
+            Species:    name,
+        })
+    }
+    save.Version = saveFileVersion
+}
+
+//Records that a species was encountered
+func (p *Pokedex) MarkSeen(species string, at time.Time) error {
     p.mu.Lock()
     defer p.mu.Unlock()
-    if _, ok := p.Entries[val.Name]; ok {
-        return fmt.Errorf("%v has allready been caught", val.Name)
-    } else {
-        p.Entries[val.Name] = val
-        fmt.Printf("You caught a %v. It was added to the Pokedex\n", val.Name)
-        return nil 
+    if _, ok := p.Seen[species]; ok {
+        return nil
     }
+    p.Seen[species] = at
+    if err := p.save(); err != nil {
+        delete(p.Seen, species)
+        return err
+    }
+    return nil
 }
 
-func (p *Pokedex) Show() error {
+//Stores a newly caught individual and registers its species in the dex. ID and Species of caught are filled in here.
+//Nothing changes if the save file cannot be written
+func (p *Pokedex) Catch(pokemon Pokemon, caught CaughtPokemon) (CaughtPokemon, error) {
     p.mu.Lock()
     defer p.mu.Unlock()
-    if len(p.Entries) < 1 {
-        return fmt.Errorf("No Entries")
-    } else {
-        for key, _ := range p.Entries {
-            fmt.Printf(" - %v\n", key)
+    _, registered := p.Entries[pokemon.Name]
+    if !registered {
+        p.Entries[pokemon.Name] = pokemon
+    }
+    _, seen := p.Seen[pokemon.SpeciesName()]
+    if !seen {
+        p.Seen[pokemon.SpeciesName()] = caught.CaughtAt
+    }
+    caught.ID = p.nextID
+    caught.Species = pokemon.Name
+    p.Box[caught.ID] = caught
+    p.nextID++
+    if err := p.save(); err != nil {
+        if !registered {
+            delete(p.Entries, pokemon.Name)
+        }
+        if !seen {
+            delete(p.Seen, pokemon.SpeciesName())
+        }
+        delete(p.Box, caught.ID)
+        p.nextID--
+        return CaughtPokemon{}, fmt.Errorf("Failed to save the Pokedex, %v was not added to your box: %v", pokemon.Name, err)
+    }
+    return caught, nil
+}
+
+//Lets the individual with id go. The species stays registered in the dex. Nothing changes if the save file cannot be
+//written
+func (p *Pokedex) Release(id int) (CaughtPokemon, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    caught, ok := p.Box[id]
+    if !ok {
+        return CaughtPokemon{}, &NotFoundError{Msg: fmt.Sprintf("There is no pokemon with id %v in your box", id)}
+    }
+    delete(p.Box, id)
+    if err := p.save(); err != nil {
+        p.Box[id] = caught
+        return CaughtPokemon{}, fmt.Errorf("Failed to save the Pokedex, %v #%v stays in your box: %v", caught.DisplayName(), id, err)
+    }
+    return caught, nil
+}
+
+// Nickname that could be mistaken for an id, a species or another individual
+type InvalidNicknameError struct {
+    Msg string
+}
+
+func (e *InvalidNicknameError) Error() string {
+    return e.Msg
+}
+
+//Gives the individual with id a nickname, an empty name removes it. The nickname has to stay reachable through
+//FindCaught, so ids, species in the dex and nicknames already in use are refused. Nothing changes if the save file
+//cannot be written
+func (p *Pokedex) SetNickname(id int, nickname string) (CaughtPokemon, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    caught, ok := p.Box[id]
+    if !ok {
+        return CaughtPokemon{}, &NotFoundError{Msg: fmt.Sprintf("There is no pokemon with id %v in your box", id)}
+    }
+    if err := p.checkNickname(id, nickname); err != nil {
+        return CaughtPokemon{}, err
+    }
+    previous := caught
+    caught.Nickname = nickname
+    p.Box[id] = caught
+    if err := p.save(); err != nil {
+        p.Box[id] = previous
+        return CaughtPokemon{}, fmt.Errorf("Failed to save the Pokedex, %v #%v keeps its name: %v", previous.DisplayName(), id, err)
+    }
+    return caught, nil
+}
+
+//Caller must hold p.mu
+func (p *Pokedex) checkNickname(id int, nickname string) error {
+    if nickname == "" {
+        return nil
+    }
+    if _, err := strconv.Atoi(nickname); err == nil {
+        return &InvalidNicknameError{Msg: fmt.Sprintf("Nickname %v is a number and would be read as an id", nickname)}
+    }
+    if p.isSpecies(nickname) {
+        return &InvalidNicknameError{Msg: fmt.Sprintf("Nickname %v is the name of a pokemon", nickname)}
+    }
+    for _, other := range p.Box {
+        if other.ID != id && other.Nickname == nickname {
+            return &InvalidNicknameError{Msg: fmt.Sprintf("Nickname %v is already used by #%v", nickname, other.ID)}
+        }
+    }
+    return nil
+}
+
+//Whether name is a pokemon or species the Pokedex knows of. Caller must hold p.mu
+func (p *Pokedex) isSpecies(name string) bool {
+    if _, ok := p.Entries[name]; ok {
+        return true
+    }
+    if _, ok := p.Seen[name]; ok {
+        return true
+    }
+    for _, pokemon := range p.Entries {
+        if pokemon.SpeciesName() == name {
+            return true
+        }
+    }
+    for _, caught := range p.Box {
+        if caught.Species == name {
+            return true
+        }
+    }
+    return false
+}
+
+//Finds a single individual by its id, nickname or species. A name only matches if exactly one individual carries it
+//as nickname or species
+func (p *Pokedex) FindCaught(ref string) (CaughtPokemon, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if id, err := strconv.Atoi(ref); err == nil {
+        if caught, ok := p.Box[id]; ok {
+            return caught, nil
+        }
+        return CaughtPokemon{}, &NotFoundError{Msg: fmt.Sprintf("There is no pokemon with id %v in your box", id)}
+    }
+    var matches []CaughtPokemon
+    var ids []string
+    for _, caught := range p.sortedBox() {
+        if caught.Nickname == ref || caught.Species == ref {
+            matches = append(matches, caught)
+            ids = append(ids, strconv.Itoa(caught.ID))
+        }
+    }
+    switch len(matches) {
+    case 0:
+        return CaughtPokemon{}, &NotFoundError{Msg: fmt.Sprintf("There is no %v in your box", ref)}
+    case 1:
+        return matches[0], nil
+    default:
+        return CaughtPokemon{}, fmt.Errorf("You own %d pokemon called %v, pick one by id: %v", len(matches), ref, strings.Join(ids, ", "))
+    }
+}
+
+//All individuals of species ordered by ID
+func (p *Pokedex) Captures(species string) []CaughtPokemon {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    captures := []CaughtPokemon{}
+    for _, caught := range p.sortedBox() {
+        if caught.Species == species {
+            captures = append(captures, caught)
         }
+    }
+    return captures
+}
+
+//All individuals ordered by ID
+func (p *Pokedex) BoxContents() []CaughtPokemon {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.sortedBox()
+}
+
+//Caller must hold p.mu
+func (p *Pokedex) sortedBox() []CaughtPokemon {
+    box := make([]CaughtPokemon, 0, len(p.Box))
+    for _, caught := range p.Box {
+        box = append(box, caught)
+    }
+    sort.Slice(box, func(i, j int) bool {
+        return box[i].ID < box[j].ID
+    })
+    return box
+}
+
+//Writes the Pokedex to its save file
+func (p *Pokedex) Save() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.save()
+}
+
+//Caller must hold p.mu. A Pokedex created without a save file path is never written to disk
+func (p *Pokedex) save() error {
+    if p.path == "" {
         return nil
     }
+    data, err := json.Marshal(saveFile{
+        Version: saveFileVersion,
+        Entries: p.Entries,
+        Seen: p.Seen,
+        Box: p.sortedBox(),
+        NextID: p.nextID,
+    })
+    if err != nil {
+        return fmt.Errorf("Failed to marshal the Pokedex with error: %v", err)
+    }
+    return writeFileAtomic(p.path, data)
+}
 
+//Names of all caught species in alphabetical order
+func (p *Pokedex) Names() []string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    names := make([]string, 0, len(p.Entries))
+    for key := range p.Entries {
+        names = append(names, key)
+    }
+    sort.Strings(names)
+    return names
+}
+
+//Number of species seen
+func (p *Pokedex) SeenCount() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return len(p.Seen)
 }
 
 func (p *Pokedex) Get(key string) (Pokemon, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
     if pokemon, ok := p.Entries[key]; !ok {
-        return Pokemon{}, fmt.Errorf("No Entry for %v. You need to catch the pokemon first.", key)
+        return Pokemon{}, &NotFoundError{Msg: fmt.Sprintf("No Entry for %v. You need to catch the pokemon first.", key)}
     } else {
         return pokemon, nil 
     } 
@@ -0,0 +1,132 @@
+package internal
+
+import (
+    "fmt";
+    "sort";
+    "strings";
+    "time";
+)
+
+// Keys the pokedex listing can be sorted by
+var PokedexSortKeys = []string{"id", "name", "height", "weight", "base-exp", "caught-at"}
+
+// Narrows down and orders the species in the Pokedex. Zero values leave the listing untouched
+type PokedexQuery struct {
+    // One of PokedexSortKeys, sorts by id if empty
+    Sort        string
+    Reverse     bool
+    Type        string
+    Ability     string
+    // Minimum base stat per stat name, e.g. attack: 80
+    MinStats    map[string]int
+    // Maximum number of entries, 0 means all
+    Limit       int
+}
+
+// A caught species together with the individuals of it in the box
+type DexEntry struct {
+    Pokemon     Pokemon
+    Captures    []CaughtPokemon
+}
+
+//Time of the earliest capture still in the box, zero if unknown
+func (e DexEntry) FirstCaught() time.Time {
+    var first time.Time
+    for _, caught := range e.Captures {
+        if first.IsZero() || (!caught.CaughtAt.IsZero() && caught.CaughtAt.Before(first)) {
+            first = caught.CaughtAt
+        }
+    }
+    return first
+}
+
+//Returns the caught species matching q in the requested order
+func (p *Pokedex) Query(q PokedexQuery) ([]DexEntry, error) {
+    less, err := dexOrder(q.Sort)
+    if err != nil {
+        return nil, err
+    }
+    p.mu.Lock()
+    box := p.sortedBox()
+    entries := []DexEntry{}
+    for _, pokemon := range p.Entries {
+        if !q.matches(pokemon) {
+            continue
+        }
+        entry := DexEntry{Pokemon: pokemon, Captures: []CaughtPokemon{}}
+        for _, caught := range box {
+            if caught.Species == pokemon.Name {
+                entry.Captures = append(entry.Captures, caught)
+            }
+        }
+        entries = append(entries, entry)
+    }
+    p.mu.Unlock()
+    sort.Slice(entries, func(i, j int) bool {
+        a, b := entries[i], entries[j]
+        if q.Reverse {
+            a, b = b, a
+        }
+        if less(a, b) != less(b, a) {
+            return less(a, b)
+        }
+        return a.Pokemon.Name < b.Pokemon.Name
+    })
+    if q.Limit > 0 && len(entries) > q.Limit {
+        entries = entries[:q.Limit]
+    }
+    return entries, nil
+}
+
+func (q PokedexQuery) matches(pokemon Pokemon) bool {
+    if q.Type != "" {
+        found := false
+        for _, pokeType := range pokemon.Types {
+            found = found || strings.EqualFold(pokeType.Type.Name, q.Type)
+        }
+        if !found {
+            return false
+        }
+    }
+    if q.Ability != "" {
+        found := false
+        for _, ability := range pokemon.Abilities {
+            found = found || strings.EqualFold(ability.Ability.Name, q.Ability)
+        }
+        if !found {
+            return false
+        }
+    }
+    for name, minimum := range q.MinStats {
+        found := false
+        for _, stat := range pokemon.Stats {
+            if stat.Stat.Name == name && stat.BaseStat >= minimum {
+                found = true
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+//Comparison for one of PokedexSortKeys
+func dexOrder(key string) (func(a, b DexEntry) bool, error) {
+    switch key {
+    case "", "id":
+        return func(a, b DexEntry) bool { return a.Pokemon.ID < b.Pokemon.ID }, nil
+    case "name":
+        return func(a, b DexEntry) bool { return a.Pokemon.Name < b.Pokemon.Name }, nil
+    case "height":
+        return func(a, b DexEntry) bool { return a.Pokemon.Height < b.Pokemon.Height }, nil
+    case "weight":
+        return func(a, b DexEntry) bool { return a.Pokemon.Weight < b.Pokemon.Weight }, nil
+    case "base-exp":
+        return func(a, b DexEntry) bool { return a.Pokemon.BaseExperience < b.Pokemon.BaseExperience }, nil
+    case "caught-at":
+        return func(a, b DexEntry) bool { return a.FirstCaught().Before(b.FirstCaught()) }, nil
+    default:
+        return nil, fmt.Errorf("Unknown sort key %v, use one of %v", key, strings.Join(PokedexSortKeys, ", "))
+    }
+}
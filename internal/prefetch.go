@@ -0,0 +1,217 @@
+package internal
+
+import (
+    "context";
+    "errors";
+    "sort";
+    "strconv";
+    "sync";
+    "sync/atomic";
+)
+
+// Progress of one prefetch phase, reported after every finished item
+type PrefetchProgress struct {
+    Phase   string
+    Done    int
+    Total   int
+}
+
+// What a prefetch run did. Entries already in the cache count as skipped, which is what makes a rerun resume.
+//NotFound counts what does not exist upstream, e.g. gaps in an id range, Failed only what a rerun may still get
+type PrefetchSummary struct {
+    Areas       int
+    Pokemon     int
+    Fetched     int
+    Skipped     int
+    Failed      int
+    NotFound    int
+    Bytes       int64
+}
+
+// Downloads location areas and every Pokemon they reference into the cache with a bounded number of workers
+type Prefetcher struct {
+    client      *Client
+    workers     int
+    progress    func(PrefetchProgress)
+    // Serialises progress calls so the callback never runs concurrently
+    progressMu  sync.Mutex
+    fetched     atomic.Int64
+    skipped     atomic.Int64
+    failed      atomic.Int64
+    notFound    atomic.Int64
+    bytes       atomic.Int64
+}
+
+//progress may be nil and is never called concurrently. workers below 1 are treated as 1
+func NewPrefetcher(client *Client, workers int, progress func(PrefetchProgress)) *Prefetcher {
+    if progress == nil {
+        progress = func(PrefetchProgress) {}
+    }
+    return &Prefetcher{
+        client:     client,
+        workers:    max(1, workers),
+        progress:   progress,
+    }
+}
+
+//Prefetches every location area of the region, e.g. kanto
+func (p *Prefetcher) Region(ctx context.Context, region string) (PrefetchSummary, error) {
+    var info Region
+    if err := p.fetchJSON(ctx, "region/"+region, &info); err != nil {
+        return p.summary(0, 0), err
+    }
+    locations := make([]string, 0, len(info.Locations))
+    for _, location := range info.Locations {
+        locations = append(locations, location.Name)
+    }
+    var mu sync.Mutex
+    var areas []string
+    err := p.each(ctx, "locations", len(locations), func(i int) string {
+        return locations[i]
+    }, func(ctx context.Context, name string) error {
+        var location Location
+        if err := p.fetchJSON(ctx, "location/"+name, &location); err != nil {
+            return err
+        }
+        mu.Lock()
+        defer mu.Unlock()
+        for _, area := range location.Areas {
+            areas = append(areas, area.Name)
+        }
+        return nil
+    })
+    if err != nil {
+        return p.summary(0, 0), err
+    }
+    return p.areas(ctx, len(areas), func(i int) string {
+        return areas[i]
+    })
+}
+
+//Prefetches the location areas with ids from first to last, both included. The ids are generated as the workers
+//get to them, so a huge range costs time but no memory
+func (p *Prefetcher) AreaRange(ctx context.Context, first int, last int) (PrefetchSummary, error) {
+    if last < first {
+        return p.summary(0, 0), nil
+    }
+    return p.areas(ctx, last-first+1, func(i int) string {
+        return strconv.Itoa(first + i)
+    })
+}
+
+//Fetches count areas, area returns the name or id of the i-th one
+func (p *Prefetcher) areas(ctx context.Context, count int, area func(int) string) (PrefetchSummary, error) {
+    var mu sync.Mutex
+    pokemon := make(map[string]bool)
+    // Only what actually made it into the cache counts, an interrupted run reports how far it got
+    var areasDone, pokemonDone atomic.Int64
+    err := p.each(ctx, "areas", count, area, func(ctx context.Context, name string) error {
+        var area LocationArea
+        if err := p.fetchJSON(ctx, "location-area/"+name, &area); err != nil {
+            return err
+        }
+        areasDone.Add(1)
+        // Commands look areas up by name, so an area fetched by id is cached under its name as well
+        p.client.alias("location-area/"+name, "location-area/"+area.Name)
+        mu.Lock()
+        defer mu.Unlock()
+        for _, encounter := range area.PokemonEncounters {
+            pokemon[encounter.Pokemon.Name] = true
+        }
+        return nil
+    })
+    if err != nil {
+        return p.summary(int(areasDone.Load()), 0), err
+    }
+    names := make([]string, 0, len(pokemon))
+    for name := range pokemon {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    err = p.each(ctx, "pokemon", len(names), func(i int) string {
+        return names[i]
+    }, func(ctx context.Context, name string) error {
+        var discard struct{}
+        if err := p.fetchJSON(ctx, "pokemon/"+name, &discard); err != nil {
+            return err
+        }
+        pokemonDone.Add(1)
+        return nil
+    })
+    return p.summary(int(areasDone.Load()), int(pokemonDone.Load())), err
+}
+
+//Runs fn for the count items item produces on the worker pool. Failed items are counted, only a cancelled ctx stops
+//the phase early
+func (p *Prefetcher) each(ctx context.Context, phase string, count int, item func(int) string, fn func(context.Context, string) error) error {
+    jobs := make(chan string)
+    var wg sync.WaitGroup
+    done := 0
+    for i := 0; i < p.workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for item := range jobs {
+                err := fn(ctx, item)
+                switch {
+                case err == nil || ctx.Err() != nil:
+                case errors.Is(err, ErrNotFound):
+                    p.notFound.Add(1)
+                default:
+                    p.failed.Add(1)
+                }
+                p.progressMu.Lock()
+                done++
+                p.progress(PrefetchProgress{
+                    Phase:  phase,
+                    Done:   done,
+                    Total:  count,
+                })
+                p.progressMu.Unlock()
+            }
+        }()
+    }
+    for i := 0; i < count; i++ {
+        select {
+        case jobs <- item(i):
+        case <-ctx.Done():
+        }
+        if ctx.Err() != nil {
+            break
+        }
+    }
+    close(jobs)
+    wg.Wait()
+    return ctx.Err()
+}
+
+//Answers from the cache, expired entries included, and only goes to the network for what is missing
+func (p *Prefetcher) fetchJSON(ctx context.Context, path string, v any) error {
+    link := p.client.baseURL + "/" + path
+    cached, ok := p.client.cache.peek(link)
+    body := cached.Val
+    if ok {
+        p.skipped.Add(1)
+    } else {
+        var err error
+        body, err = p.client.get(ctx, link)
+        if err != nil {
+            return err
+        }
+        p.fetched.Add(1)
+        p.bytes.Add(int64(len(body)))
+    }
+    return decodeJSON(link, body, v)
+}
+
+func (p *Prefetcher) summary(areas int, pokemon int) PrefetchSummary {
+    return PrefetchSummary{
+        Areas:      areas,
+        Pokemon:    pokemon,
+        Fetched:    int(p.fetched.Load()),
+        Skipped:    int(p.skipped.Load()),
+        Failed:     int(p.failed.Load()),
+        NotFound:   int(p.notFound.Load()),
+        Bytes:      p.bytes.Load(),
+    }
+}
@@ -0,0 +1,252 @@
+package internal
+
+import (
+    "context";
+    "net/url";
+    "path";
+    "sort";
+    "strconv";
+    "strings";
+)
+
+// A species listed in a dex with its number there, the national number where the dex has none of its own
+type DexSpecies struct {
+    Number  int     `json:"number"`
+    Name    string  `json:"name"`
+}
+
+// The species a national or regional dex, generation or game version covers
+type Dex struct {
+    Name    string
+    Species []DexSpecies
+}
+
+// How far the player is with a Dex
+type DexProgress struct {
+    Dex     string
+    Total   int
+    Seen    int
+    Caught  int
+    // Species not caught yet in dex order
+    Missing []MissingSpecies
+}
+
+type MissingSpecies struct {
+    DexSpecies
+    Seen    bool        `json:"seen"`
+    // Cached location areas the species can be encountered in
+    Areas   []string    `json:"areas"`
+}
+
+type pokedexResource struct {
+    Name            string  `json:"name"`
+    PokemonEntries  []struct {
+        EntryNumber     int `json:"entry_number"`
+        PokemonSpecies  struct {
+            Name string `json:"name"`
+            URL  string `json:"url"`
+        } `json:"pokemon_species"`
+    } `json:"pokemon_entries"`
+}
+
+type generationResource struct {
+    Name            string  `json:"name"`
+    PokemonSpecies  []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"pokemon_species"`
+}
+
+type versionResource struct {
+    Name            string  `json:"name"`
+    VersionGroup    struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"version_group"`
+}
+
+type versionGroupResource struct {
+    Name        string  `json:"name"`
+    Pokedexes   []struct {
+        Name string `json:"name"`
+        URL  string `json:"url"`
+    } `json:"pokedexes"`
+}
+
+//Fetches a pokedex such as national or kanto
+func (c *Client) GetDex(ctx context.Context, name string) (Dex, error) {
+    var resource pokedexResource
+    if err := c.getJSON(ctx, c.baseURL+"/pokedex/"+url.PathEscape(name), &resource); err != nil {
+        return Dex{}, err
+    }
+    dex := Dex{Name: resource.Name}
+    for _, entry := range resource.PokemonEntries {
+        dex.Species = append(dex.Species, DexSpecies{Number: entry.EntryNumber, Name: entry.PokemonSpecies.Name})
+    }
+    return dex, nil
+}
+
+//All regional dexes of a region merged into one, e.g. kanto
+func (c *Client) GetRegionDex(ctx context.Context, name string) (Dex, error) {
+    var region Region
+    if err := c.getJSON(ctx, c.baseURL+"/region/"+url.PathEscape(name), &region); err != nil {
+        return Dex{}, err
+    }
+    names := make([]string, 0, len(region.Pokedexes))
+    for _, pokedex := range region.Pokedexes {
+        names = append(names, pokedex.Name)
+    }
+    return c.mergedDex(ctx, region.Name, names)
+}
+
+//The species introduced in a generation, by name (generation-i) or number, in national order
+func (c *Client) GetGenerationDex(ctx context.Context, name string) (Dex, error) {
+    var generation generationResource
+    if err := c.getJSON(ctx, c.baseURL+"/generation/"+url.PathEscape(name), &generation); err != nil {
+        return Dex{}, err
+    }
+    dex := Dex{Name: generation.Name}
+    for _, species := range generation.PokemonSpecies {
+        number, _ := strconv.Atoi(path.Base(strings.TrimSuffix(species.URL, "/")))
+        dex.Species = append(dex.Species, DexSpecies{Number: number, Name: species.Name})
+    }
+    sort.SliceStable(dex.Species, func(i, j int) bool {
+        return dex.Species[i].Number < dex.Species[j].Number
+    })
+    return dex, nil
+}
+
+//The dexes of the version group a game version belongs to merged into one, e.g. red
+func (c *Client) GetVersionDex(ctx context.Context, name string) (Dex, error) {
+    var version versionResource
+    if err := c.getJSON(ctx, c.baseURL+"/version/"+url.PathEscape(name), &version); err != nil {
+        return Dex{}, err
+    }
+    var group versionGroupResource
+    if err := c.getJSON(ctx, c.baseURL+"/version-group/"+url.PathEscape(version.VersionGroup.Name), &group); err != nil {
+        return Dex{}, err
+    }
+    names := make([]string, 0, len(group.Pokedexes))
+    for _, pokedex := range group.Pokedexes {
+        names = append(names, pokedex.Name)
+    }
+    return c.mergedDex(ctx, version.Name, names)
+}
+
+//Fetches the named dexes and lists every species once, numbered by the first dex that has it
+func (c *Client) mergedDex(ctx context.Context, name string, pokedexes []string) (Dex, error) {
+    merged := Dex{Name: name}
+    listed := make(map[string]bool)
+    for _, pokedex := range pokedexes {
+        dex, err := c.GetDex(ctx, pokedex)
+        if err != nil {
+            return Dex{}, err
+        }
+        for _, species := range dex.Species {
+            if !listed[species.Name] {
+                listed[species.Name] = true
+                merged.Species = append(merged.Species, species)
+            }
+        }
+    }
+    return merged, nil
+}
+
+//Maps species names to the cached location areas they appear in. Only looks at the cache, never the network
+func (c *Client) CachedEncounters() map[string][]string {
+    encounters := make(map[string][]string)
+    // Decoding a pokemon is costly and most of them show up in many areas
+    speciesOf := make(map[string]string)
+    for _, name := range c.CachedNames("location-area") {
+        link := c.baseURL + "/location-area/" + url.PathEscape(name)
+        cached, ok := c.cache.peek(link)
+        if !ok {
+            continue
+        }
+        var area LocationArea
+        if decodeJSON(link, cached.Val, &area) != nil {
+            continue
+        }
+        for _, encounter := range area.PokemonEncounters {
+            species, ok := speciesOf[encounter.Pokemon.Name]
+            if !ok {
+                species = c.cachedSpeciesName(encounter.Pokemon.Name)
+                speciesOf[encounter.Pokemon.Name] = species
+            }
+            encounters[species] = append(encounters[species], area.Name)
+        }
+    }
+    for name, areas := range encounters {
+        sort.Strings(areas)
+        encounters[name] = compactStrings(areas)
+    }
+    return encounters
+}
+
+//Species of the pokemon called name if the cache has the pokemon, its own name otherwise
+func (c *Client) cachedSpeciesName(name string) string {
+    link := c.baseURL + "/pokemon/" + url.PathEscape(name)
+    cached, ok := c.cache.peek(link)
+    if !ok {
+        return name
+    }
+    var pokemon Pokemon
+    if decodeJSON(link, cached.Val, &pokemon) != nil {
+        return name
+    }
+    return pokemon.SpeciesName()
+}
+
+//Drops adjacent duplicates from a sorted list
+func compactStrings(list []string) []string {
+    compacted := list[:0]
+    for i, item := range list {
+        if i == 0 || item != list[i-1] {
+            compacted = append(compacted, item)
+        }
+    }
+    return compacted
+}
+
+//Compares the seen and caught species against dex. encounters comes from Client.CachedEncounters
+func (p *Pokedex) Progress(dex Dex, encounters map[string][]string) DexProgress {
+    p.mu.Lock()
+    caught := make(map[string]bool)
+    seen := make(map[string]bool)
+    for _, pokemon := range p.Entries {
+        caught[pokemon.SpeciesName()] = true
+        seen[pokemon.SpeciesName()] = true
+    }
+    for name := range p.Seen {
+        //Older saves recorded the pokemon rather than the species
+        if pokemon, ok := p.Entries[name]; ok {
+            name = pokemon.SpeciesName()
+        }
+        seen[name] = true
+    }
+    p.mu.Unlock()
+    progress := DexProgress{
+        Dex:        dex.Name,
+        Total:      len(dex.Species),
+        Missing:    []MissingSpecies{},
+    }
+    for _, species := range dex.Species {
+        if seen[species.Name] {
+            progress.Seen++
+        }
+        if caught[species.Name] {
+            progress.Caught++
+            continue
+        }
+        areas := encounters[species.Name]
+        if areas == nil {
+            areas = []string{}
+        }
+        progress.Missing = append(progress.Missing, MissingSpecies{
+            DexSpecies: species,
+            Seen:       seen[species.Name],
+            Areas:      areas,
+        })
+    }
+    return progress
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+    "sort";
+    "strings";
+)
+
+// Kinds of things search looks through
+const (
+    SearchPokemon       = "pokemon"
+    SearchNickname      = "nickname"
+    SearchMove          = "move"
+    SearchAbility       = "ability"
+    SearchLocationArea  = "location-area"
+)
+
+// A name search can find. Sources lists the caught species a move or ability belongs to, or the species of a nickname
+type SearchItem struct {
+    Kind        string      `json:"kind"`
+    Name        string      `json:"name"`
+    Sources     []string    `json:"sources,omitempty"`
+}
+
+type SearchMatch struct {
+    SearchItem
+    // Edit distance between the query and the name, 0 for substring matches
+    Distance    int         `json:"distance"`
+}
+
+//Splits items into names containing query and names that are merely close to it, both best first
+func Search(query string, items []SearchItem) (matches []SearchMatch, suggestions []SearchMatch) {
+    query = normalizeName(query)
+    for _, item := range items {
+        name := normalizeName(item.Name)
+        if strings.Contains(name, query) {
+            matches = append(matches, SearchMatch{SearchItem: item})
+            continue
+        }
+        if distance, ok := fuzzyDistance(query, name); ok {
+            suggestions = append(suggestions, SearchMatch{SearchItem: item, Distance: distance})
+        }
+    }
+    sort.SliceStable(matches, func(i, j int) bool {
+        a, b := matches[i], matches[j]
+        aPrefix, bPrefix := strings.HasPrefix(normalizeName(a.Name), query), strings.HasPrefix(normalizeName(b.Name), query)
+        if aPrefix != bPrefix {
+            return aPrefix
+        }
+        if len(a.Name) != len(b.Name) {
+            return len(a.Name) < len(b.Name)
+        }
+        if a.Name != b.Name {
+            return a.Name < b.Name
+        }
+        return a.Kind < b.Kind
+    })
+    sort.SliceStable(suggestions, func(i, j int) bool {
+        a, b := suggestions[i], suggestions[j]
+        if a.Distance != b.Distance {
+            return a.Distance < b.Distance
+        }
+        if a.Name != b.Name {
+            return a.Name < b.Name
+        }
+        return a.Kind < b.Kind
+    })
+    return matches, suggestions
+}
+
+//The candidate closest to name, ok is false if none of them is close enough to be a likely typo
+func Closest(name string, candidates []string) (closest string, ok bool) {
+    name = normalizeName(name)
+    best := -1
+    for _, candidate := range candidates {
+        distance, close := fuzzyDistance(name, normalizeName(candidate))
+        if !close && !strings.Contains(normalizeName(candidate), name) {
+            continue
+        }
+        if best == -1 || distance < best || (distance == best && candidate < closest) {
+            closest, best = candidate, distance
+        }
+    }
+    return closest, best != -1
+}
+
+//PokeAPI names are lower case with dashes, users tend to type spaces
+func normalizeName(name string) string {
+    return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+}
+
+//Distance between query and name or the closest dash separated part of name, ok reports whether it is within typo range
+func fuzzyDistance(query, name string) (int, bool) {
+    distance := editDistance(query, name)
+    if parts := strings.Split(name, "-"); len(parts) > 1 {
+        for _, part := range parts {
+            distance = min(distance, editDistance(query, part))
+        }
+    }
+    return distance, distance <= max(1, len([]rune(query))/3)
+}
+
+//Number of single rune insertions, deletions, substitutions and swaps of neighbouring runes that turn a into b
+func editDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    beforePrevious := make([]int, len(rb)+1)
+    previous := make([]int, len(rb)+1)
+    current := make([]int, len(rb)+1)
+    for j := range previous {
+        previous[j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        current[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+            if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+                current[j] = min(current[j], beforePrevious[j-2]+1)
+            }
+        }
+        beforePrevious, previous, current = previous, current, beforePrevious
+    }
+    return previous[len(rb)]
+}
+
+//Caught species, nicknames and the moves and abilities of the caught species as search items
+func (p *Pokedex) SearchItems() []SearchItem {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    var items []SearchItem
+    moves := make(map[string][]string)
+    abilities := make(map[string][]string)
+    for name, pokemon := range p.Entries {
+        items = append(items, SearchItem{Kind: SearchPokemon, Name: name})
+        for _, move := range pokemon.Moves {
+            moves[move.Move.Name] = append(moves[move.Move.Name], name)
+        }
+        for _, ability := range pokemon.Abilities {
+            abilities[ability.Ability.Name] = append(abilities[ability.Ability.Name], name)
+        }
+    }
+    for _, caught := range p.sortedBox() {
+        if caught.Nickname != "" {
+            items = append(items, SearchItem{Kind: SearchNickname, Name: caught.Nickname, Sources: []string{caught.Species}})
+        }
+    }
+    for name, sources := range moves {
+        sort.Strings(sources)
+        items = append(items, SearchItem{Kind: SearchMove, Name: name, Sources: sources})
+    }
+    for name, sources := range abilities {
+        sort.Strings(sources)
+        items = append(items, SearchItem{Kind: SearchAbility, Name: name, Sources: sources})
+    }
+    return items
+}
+
+//Nicknames given to any individual in the box
+func (p *Pokedex) Nicknames() []string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    var nicknames []string
+    for _, caught := range p.sortedBox() {
+        if caught.Nickname != "" {
+            nicknames = append(nicknames, caught.Nickname)
+        }
+    }
+    return nicknames
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+    "context";
+    "errors";
+    "io";
+    "math/rand/v2";
+    "net";
+    "net/http";
+    "strconv";
+    "sync";
+    "time";
+)
+
+const (
+    defaultRetryBaseDelay = 250 * time.Millisecond
+    defaultRetryMaxDelay = 10 * time.Second
+)
+
+// http.RoundTripper shared by all requests. Retries idempotent requests with jittered exponential backoff on
+// 5xx, 429 and timeouts and keeps the request rate under a client side limit. Retry-After is honoured, a response
+// asking for a longer wait than maxDelay is returned instead of retried
+type RetryTransport struct {
+    base        http.RoundTripper
+    maxRetries  int
+    baseDelay   time.Duration
+    maxDelay    time.Duration
+    limiter     *rateLimiter
+}
+
+//Wraps base, nil means http.DefaultTransport. A requestsPerSecond of 0 or less disables the rate limit
+func NewRetryTransport(base http.RoundTripper, maxRetries int, requestsPerSecond float64) *RetryTransport {
+    if base == nil {
+        base = http.DefaultTransport
+    }
+    t := &RetryTransport{
+        base:       base,
+        maxRetries: maxRetries,
+        baseDelay:  defaultRetryBaseDelay,
+        maxDelay:   defaultRetryMaxDelay,
+    }
+    if requestsPerSecond > 0 {
+        t.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+    }
+    return t
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    ctx := req.Context()
+    retryable := (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+    for attempt := 0; ; attempt++ {
+        if t.limiter != nil {
+            if err := t.limiter.wait(ctx); err != nil {
+                return nil, err
+            }
+        }
+        res, err := t.base.RoundTrip(req)
+        if !retryable || attempt >= t.maxRetries || !shouldRetry(ctx, res, err) {
+            return res, err
+        }
+        delay := t.backoff(attempt)
+        if res != nil {
+            if after, ok := retryAfter(res); ok {
+                //Asking again early would ignore the server, waiting longer than maxDelay stalls the command
+                if after > t.maxDelay {
+                    return res, nil
+                }
+                delay = after
+            }
+            // Drain the body so the connection can be reused
+            io.Copy(io.Discard, res.Body)
+            res.Body.Close()
+        }
+        if err := sleep(ctx, delay); err != nil {
+            return nil, err
+        }
+    }
+}
+
+//Full jitter: a random delay between 0 and the exponential cap for the attempt
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+    ceiling := t.baseDelay << attempt
+    if ceiling <= 0 || ceiling > t.maxDelay {
+        ceiling = t.maxDelay
+    }
+    return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+func shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+    if ctx.Err() != nil {
+        return false
+    }
+    if err != nil {
+        var netErr net.Error
+        return errors.As(err, &netErr) && netErr.Timeout()
+    }
+    return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+//Reads Retry-After given either in seconds or as an HTTP date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+    header := res.Header.Get("Retry-After")
+    if header == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second, true
+    }
+    if at, err := http.ParseTime(header); err == nil {
+        return max(0, time.Until(at)), true
+    }
+    return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
+// Hands out evenly spaced request slots
+type rateLimiter struct {
+    mu          sync.Mutex
+    interval    time.Duration
+    next        time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+    l.mu.Lock()
+    now := time.Now()
+    slot := l.next
+    if slot.Before(now) {
+        slot = now
+    }
+    l.next = slot.Add(l.interval)
+    l.mu.Unlock()
+    return sleep(ctx, slot.Sub(now))
+}
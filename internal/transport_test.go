@@ -0,0 +1,92 @@
+package internal
+
+import (
+    "net/http";
+    "net/http/httptest";
+    "strings";
+    "sync/atomic";
+    "testing";
+    "time";
+)
+
+//Serves the statuses in order, one per request, and 200 once they run out. Retry-After is sent along with every
+//status that is not 200
+func newStatusServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+    t.Helper()
+    var requests atomic.Int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := int(requests.Add(1))
+        if n <= len(statuses) {
+            if retryAfter != "" {
+                w.Header().Set("Retry-After", retryAfter)
+            }
+            w.WriteHeader(statuses[n-1])
+            return
+        }
+        w.Write([]byte("ok"))
+    }))
+    t.Cleanup(server.Close)
+    return server, &requests
+}
+
+func newTestTransport() *RetryTransport {
+    t := NewRetryTransport(nil, 3, 0)
+    t.baseDelay = time.Millisecond
+    return t
+}
+
+func TestRetryTransportRetriesServerErrors(t *testing.T) {
+    server, requests := newStatusServer(t, "", http.StatusInternalServerError, http.StatusBadGateway)
+    client := &http.Client{Transport: newTestTransport()}
+    res, err := client.Get(server.URL)
+    if err != nil {
+        t.Fatalf("Get failed: %v", err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK || requests.Load() != 3 {
+        t.Fatalf("got %v after %d requests, want 200 after 3", res.StatusCode, requests.Load())
+    }
+}
+
+func TestRetryTransportWaitsForRetryAfter(t *testing.T) {
+    server, requests := newStatusServer(t, "1", http.StatusTooManyRequests)
+    client := &http.Client{Transport: newTestTransport()}
+    start := time.Now()
+    res, err := client.Get(server.URL)
+    if err != nil {
+        t.Fatalf("Get failed: %v", err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK || requests.Load() != 2 {
+        t.Fatalf("got %v after %d requests, want 200 after 2", res.StatusCode, requests.Load())
+    }
+    if elapsed := time.Since(start); elapsed < time.Second {
+        t.Fatalf("retried after %v, before the 1s Retry-After was up", elapsed)
+    }
+}
+
+func TestRetryTransportReturnsLongRetryAfter(t *testing.T) {
+    server, requests := newStatusServer(t, "120", http.StatusServiceUnavailable)
+    client := &http.Client{Transport: newTestTransport()}
+    res, err := client.Get(server.URL)
+    if err != nil {
+        t.Fatalf("Get failed: %v", err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusServiceUnavailable || requests.Load() != 1 {
+        t.Fatalf("got %v after %d requests, want the 503 after 1", res.StatusCode, requests.Load())
+    }
+}
+
+func TestRetryTransportDoesNotRetryPost(t *testing.T) {
+    server, requests := newStatusServer(t, "", http.StatusInternalServerError)
+    client := &http.Client{Transport: newTestTransport()}
+    res, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+    if err != nil {
+        t.Fatalf("Post failed: %v", err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusInternalServerError || requests.Load() != 1 {
+        t.Fatalf("got %v after %d requests, want the 500 after 1", res.StatusCode, requests.Load())
+    }
+}
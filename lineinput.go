@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// Source of input lines for the REPL, returns io.EOF once there is nothing left
+type lineReader interface {
+    readLine(prompt string) (string, error)
+    close() error
+}
+
+// Plain line reader for pipes, files and anything else that is not a terminal
+type scannerLineReader struct {
+    scanner *bufio.Scanner
+    out io.Writer
+}
+
+func (s *scannerLineReader) readLine(prompt string) (string, error) {
+    fmt.Fprint(s.out, prompt)
+    if !s.scanner.Scan() {
+        if err := s.scanner.Err(); err != nil {
+            return "", err
+        }
+        return "", io.EOF
+    }
+    return s.scanner.Text(), nil
+}
+
+func (s *scannerLineReader) close() error {
+    return nil
+}
+
+// Terminal line editor with arrow key history, Ctrl-R search and tab completion
+type terminalLineReader struct {
+    state *liner.State
+    historyPath string
+}
+
+func newTerminalLineReader(historyPath string, completer liner.WordCompleter) *terminalLineReader {
+    state := liner.NewLiner()
+    state.SetCtrlCAborts(true)
+    state.SetTabCompletionStyle(liner.TabPrints)
+    state.SetWordCompleter(completer)
+    if historyPath != "" {
+        if file, err := os.Open(historyPath); err == nil {
+            state.ReadHistory(file)
+            file.Close()
+        }
+    }
+    return &terminalLineReader{
+        state: state,
+        historyPath: historyPath,
+    }
+}
+
+func (t *terminalLineReader) readLine(prompt string) (string, error) {
+    line, err := t.state.Prompt(prompt)
+    // Ctrl-C at the prompt only throws away the line that is being typed
+    if errors.Is(err, liner.ErrPromptAborted) {
+        return "", nil
+    }
+    if err != nil {
+        return "", err
+    }
+    if strings.TrimSpace(line) != "" {
+        t.state.AppendHistory(line)
+    }
+    return line, nil
+}
+
+//Restores the terminal and writes the history back to disk
+func (t *terminalLineReader) close() error {
+    defer t.state.Close()
+    if t.historyPath == "" {
+        return nil
+    }
+    if err := os.MkdirAll(filepath.Dir(t.historyPath), 0o755); err != nil {
+        return fmt.Errorf("Failed to create directory for history file with error: %v", err)
+    }
+    file, err := os.Create(t.historyPath)
+    if err != nil {
+        return fmt.Errorf("Failed to write history file with error: %v", err)
+    }
+    defer file.Close()
+    if _, err := t.state.WriteHistory(file); err != nil {
+        return fmt.Errorf("Failed to write history file with error: %v", err)
+    }
+    return nil
+}
+
+func isTerminal(file *os.File) bool {
+    info, err := file.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+//Completes the word under the cursor: command names first, then arguments that fit the command
+func (r *repl) complete(line string, pos int) (head string, completions []string, tail string) {
+    head, tail = line[:pos], line[pos:]
+    start := strings.LastIndexAny(head, " \t") + 1
+    word := head[start:]
+    head = head[:start]
+    previous := strings.Fields(head)
+    var candidates []string
+    if len(previous) == 0 {
+        for name := range r.commands {
+            candidates = append(candidates, name)
+        }
+    } else if len(previous) == 1 {
+        switch previous[0] {
+        case "explore":
+            candidates = r.config.lastLocations
+        case "catch":
+            candidates = r.config.areaPokemon
+        case "inspect":
+            candidates = r.config.pokedex.Names()
+        case "nickname", "release":
+            for _, caught := range r.config.pokedex.BoxContents() {
+                candidates = append(candidates, strconv.Itoa(caught.ID))
+            }
+        }
+    }
+    for _, candidate := range candidates {
+        if strings.HasPrefix(candidate, word) {
+            completions = append(completions, candidate)
+        }
+    }
+    sort.Strings(completions)
+    return head, completions, tail
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"math/rand/v2"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TheGeneral00/pokedexcli/internal"
 )
@@ -16,80 +23,64 @@ import (
 type cliCommand struct {
     name string
     description string 
-    callback func(*config) error
+    usage string
+    // Bounds on the number of positional arguments, maxArgs of -1 means no upper bound
+    minArgs int
+    maxArgs int
+    // Names of the --flags the command accepts
+    flags []string
+    // The flags that need a value, these may be written as --name value as well as --name=value
+    valueFlags []string
+    // Long running commands that are only stopped by an interrupt, not by the -timeout
+    noTimeout bool
+    // Returns a result for render, or nil if there is nothing to print
+    callback func(context.Context, *config, io.Writer, arguments) (any, error)
 }
 
 type config struct {
     cache *internal.Cache
     pokedex *internal.Pokedex
-    prev string
-    next string
-    current string
+    client *internal.Client
+    page int
+    hasNext bool
     currentLocation string
-    additionalInput string
-}
-
-type Response struct {
-	Count    int    `json:"count"`
-	Next     string `json:"next"`
-	Previous string `json:"previous"`
-	Results  []struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"results"`
-}
-
-type exploreResponse struct {
-	ID                   int    `json:"id"`
-	Name                 string `json:"name"`
-	GameIndex            int    `json:"game_index"`
-	EncounterMethodRates []struct {
-		EncounterMethod struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"encounter_method"`
-		VersionDetails []struct {
-			Rate    int `json:"rate"`
-			Version struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-		} `json:"version_details"`
-	} `json:"encounter_method_rates"`
-	Location struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"location"`
-	Names []struct {
-		Name     string `json:"name"`
-		Language struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"language"`
-	} `json:"names"`
-	PokemonEncounters []struct {
-		Pokemon struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"pokemon"`
-		VersionDetails []struct {
-			Version struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-			MaxChance        int `json:"max_chance"`
-			EncounterDetails []struct {
-				MinLevel        int   `json:"min_level"`
-				MaxLevel        int   `json:"max_level"`
-				ConditionValues []any `json:"condition_values"`
-				Chance          int   `json:"chance"`
-				Method          struct {
-					Name string `json:"name"`
-					URL  string `json:"url"`
-				} `json:"method"`
-			} `json:"encounter_details"`
-		} `json:"version_details"`
-	} `json:"pokemon_encounters"`
+    // Completion candidates for explore and catch
+    lastLocations []string
+    areaPokemon []string
+    rng *rand.Rand
+    // False when running a single command from the shell, which suppresses everything but the result
+    interactive bool
+    // Upper bound on how long a single command may take, 0 means no limit
+    timeout time.Duration
+    // Format set by -output and the one resolved for the command that is currently running
+    defaultOutput string
+    output string
+}
+
+// Exit codes of the non-interactive mode
+const (
+    exitOK = 0
+    exitError = 1
+    exitUsage = 2
+    exitNotFound = 3
+    exitInterrupted = 130
+)
+
+const defaultPrefetchWorkers = 8
+
+//Context for a single command, bounded by the configured timeout unless the command opts out
+func (c *config) commandContext(parent context.Context, command cliCommand) (context.Context, context.CancelFunc) {
+    if c.timeout > 0 && !command.noTimeout {
+        return context.WithTimeout(parent, c.timeout)
+    }
+    return context.WithCancel(parent)
+}
+
+//Prints progress chatter that only makes sense in the REPL
+func (c *config) chatf(w io.Writer, format string, a ...any) {
+    if c.interactive && c.output == outputText {
+        fmt.Fprintf(w, format, a...)
+    }
 }
 
 func getCommands() map[string]cliCommand {
@@ -97,279 +88,728 @@ func getCommands() map[string]cliCommand {
         "help": {
             name:   "help",
             description:    "Displayes a help message",
+            usage:          "help",
             callback:       commandHelp,
         },
         "exit": {
             name:           "exit",
             description:    "Exit the Pokedex",
+            usage:          "exit",
             callback:       commandExit,
         },
         "map": {
-            name:           "map ",
+            name:           "map",
             description:    "Displays the name of 20 locations",
+            usage:          "map",
             callback:       commandMap,
         },
         "mapb": {
             name:           "mapb",
             description:    "Displays the locations from the previous map call",
+            usage:          "mapb",
             callback:       commandMapB,
         },
         "printResponse": {
             name:           "printResponse",
             description:    "Debugging function that prints the response of a http request",
+            usage:          "printResponse",
             callback:       printResponse,
         },
         "explore": {
             name:           "explore",
             description:    "Shows the pokemon located in the area",
+            usage:          "explore <location-area>",
+            minArgs:        1,
+            maxArgs:        1,
             callback:       commandExplore,
         },
         "catch":    {
             name:           "catch",
             description:    "Allows you to try to catch the pokemon discovered by exploring the area",
+            usage:          "catch <pokemon> [--area=<location-area>]",
+            minArgs:        1,
+            maxArgs:        1,
+            flags:          []string{"area"},
+            valueFlags:     []string{"area"},
             callback:       commandCatch,
         },
         "inspect":  {
             name:           "inspect",
             description:    "Gives detailed information about the pokemon in your pokedex",
+            usage:          "inspect <pokemon|id|nickname>",
+            minArgs:        1,
+            maxArgs:        1,
             callback:       commandInspect,
         },
+        "cache":    {
+            name:           "cache",
+            description:    "Shows and manages what the cache holds. Keys may be given relative to the base URL, e.g. pokemon/",
+            usage:          "cache status | cache stats | cache list [prefix] | cache evict <key|prefix> | cache clear",
+            minArgs:        1,
+            maxArgs:        2,
+            callback:       commandCache,
+        },
+        "prefetch": {
+            name:           "prefetch",
+            description:    "Downloads location areas and their pokemon into the cache for offline play, rerun it to resume",
+            usage:          "prefetch region <region> | prefetch areas <first>-<last> [--workers=<n>]",
+            minArgs:        2,
+            maxArgs:        2,
+            flags:          []string{"workers"},
+            valueFlags:     []string{"workers"},
+            noTimeout:      true,
+            callback:       commandPrefetch,
+        },
         "pokedex":  {
             name:           "pokedex",
-            description:    "Lists all your caught pokemon",
+            description:    "Lists all your caught pokemon as a table, optionally filtered and sorted",
+            usage:          "pokedex [--sort=id|name|height|weight|base-exp|caught-at] [--reverse] [--type=<type>] [--ability=<ability>] [--min-stat=<stat>=<value>,...] [--limit=<n>]",
+            flags:          []string{"sort", "reverse", "type", "ability", "min-stat", "limit"},
+            valueFlags:     []string{"sort", "type", "ability", "min-stat", "limit"},
             callback:       commandPokedex,
         },
+        "box":  {
+            name:           "box",
+            description:    "Lists every individual pokemon you caught with its id",
+            usage:          "box",
+            callback:       commandBox,
+        },
+        "release":  {
+            name:           "release",
+            description:    "Releases the pokemon with the given id from your box",
+            usage:          "release <id>",
+            minArgs:        1,
+            maxArgs:        1,
+            callback:       commandRelease,
+        },
+        "progress": {
+            name:           "progress",
+            description:    "Shows how many species of a dex you have seen and caught and where to find the missing ones",
+            usage:          "progress [national | dex <pokedex> | region <region> | generation <generation> | version <version>] [--limit=<n>]",
+            minArgs:        0,
+            maxArgs:        2,
+            flags:          []string{"limit"},
+            valueFlags:     []string{"limit"},
+            callback:       commandProgress,
+        },
+        "search":   {
+            name:           "search",
+            description:    "Finds caught pokemon, nicknames, moves, abilities and cached location areas by name, suggesting close matches for typos",
+            usage:          "search <query> [--kind=pokemon|nickname|move|ability|location-area]",
+            minArgs:        1,
+            maxArgs:        -1,
+            flags:          []string{"kind"},
+            valueFlags:     []string{"kind"},
+            callback:       commandSearch,
+        },
+        "nickname": {
+            name:           "nickname",
+            description:    "Gives one of your pokemon a nickname, an empty name removes it",
+            usage:          "nickname <pokemon|id|nickname> <name>",
+            minArgs:        2,
+            maxArgs:        2,
+            callback:       commandNickname,
+        },
     }
 }
 
-func commandExit(*config) error {
-    fmt.Println("Exiting program")
-    os.Exit(0)
-    return nil
+func commandExit(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    config.chatf(w, "Exiting program\n")
+    if err := config.pokedex.Save(); err != nil {
+        return nil, fmt.Errorf("Failed to save the Pokedex, not exiting: %v", err)
+    }
+    return nil, errExit
 }
 
-func commandHelp(*config) error {
+func commandHelp(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
     commands := getCommands()
-    fmt.Println("Welcome to the Pokedex!\n\nUsage:\n")
-    for name, content := range commands {
-        fmt.Println(name, ":", content.description)
+    names := make([]string, 0, len(commands))
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var result helpResult
+    for _, name := range names {
+        result.Commands = append(result.Commands, helpEntry{
+            Name:           name,
+            Description:    commands[name].description,
+            Usage:          commands[name].usage,
+        })
+    }
+    return result, nil
+}
+
+func commandMap(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    if config.page > 0 && !config.hasNext {
+        return nil, fmt.Errorf("There are no more locations to show")
     }
-    fmt.Println("\n")
-    return nil
+    return showLocationPage(ctx, config, config.page+1)
 }
 
-func commandMap(config *config) error {
-    res, err := http.Get("https://pokeapi.co/api/v2/location-area?offset=0&limit=20")
-    if config.next != ""{
-        res, err = http.Get(config.next)
+func commandMapB(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    if config.page < 2 {
+        return nil, fmt.Errorf("There are no locations to go back to")
     }
+    return showLocationPage(ctx, config, config.page-1)
+}
+
+func showLocationPage(ctx context.Context, config *config, page int) (any, error) {
+    list, err := config.client.ListLocationAreas(ctx, page)
     if err != nil {
-        return fmt.Errorf("Locations couldn't be displayed with error: %v", err)
+        return nil, fmt.Errorf("Locations couldn't be displayed with error: %v", err)
+    }
+    result := locationPageResult{
+        Page:       page,
+        Locations:  []string{},
     }
-    defer res.Body.Close()
-    if res.StatusCode > 299 {
-        return fmt.Errorf("Response failed with status code: %d", res.StatusCode)
+    for _, location := range list.Results {
+        result.Locations = append(result.Locations, location.Name)
     }
-    var response Response
-    rawByteSlice, err := io.ReadAll(res.Body)
+    config.page = page
+    config.hasNext = list.Next != ""
+    config.lastLocations = result.Locations
+    return result, nil
+}
+
+func printResponse(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    body, err := config.client.GetRaw(ctx, "/location-area")
     if err != nil {
-        return fmt.Errorf("Failed reading response Body to byte slice with error: %v", err)
+        return nil, err
+    }
+    return rawResponse(body), nil
+}
+
+func commandExplore(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    name := args.positional[0]
+    config.chatf(w, "Exploring %v\n", name)
+    area, err := config.client.GetLocationArea(ctx, name)
+    if errors.Is(err, internal.ErrNotFound) {
+        err = &internal.NotFoundError{Msg: fmt.Sprintf("There is no location area called %v", name)}
+        return nil, suggest(err, name, append(config.client.CachedNames("location-area"), config.lastLocations...))
     }
-    err = json.Unmarshal(rawByteSlice, &response)
     if err != nil {
-        return fmt.Errorf("Failed unmarshaling response with error: %v", err)
+        return nil, err
     }
-    for _, location := range response.Results {
-        fmt.Println(location.Name)
+    result := exploreResult{
+        Area:       area.Name,
+        Pokemon:    []string{},
     }
-    
-    config.next = response.Next
-    config.prev = response.Previous
-    config.current = res.Request.URL.String()
-    config.cache.Add(config.current, rawByteSlice)
-    fmt.Println("Storing URL:", res.Request.URL.String()) 
-    return nil
+    for _, encounter := range area.PokemonEncounters {
+        result.Pokemon = append(result.Pokemon, encounter.Pokemon.Name)
+    }
+    config.currentLocation = area.Name
+    config.areaPokemon = result.Pokemon
+    return result, nil 
 }
 
-func commandMapB(config *config) error {
-    if config.prev == ""{
-        return fmt.Errorf("There are no locations to go back to")
+func commandCatch(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    name := args.positional[0]
+    //The flag only becomes the current location once the area turned out to exist
+    areaName := config.currentLocation
+    if area, ok := args.flag("area"); ok {
+        areaName = area
     }
-    fmt.Printf("Requested URL: %v\n", config.prev)
-    var response Response
-    if val, ok := config.cache.Get(config.prev); ok {
-        err := json.Unmarshal(val, &response)    
-        if err != nil {
-            return fmt.Errorf("Failed to unmarshal the raw byte val from cache with error: %v", err)
-        }
-    } else {
-        res, err := http.Get(config.prev)
-        defer res.Body.Close()
-        if err != nil {
-            return fmt.Errorf("Response failed with error: %v", err)
-        }
-        if res.StatusCode > 299 {
-            return fmt.Errorf("Response failed with status code: %d", res.StatusCode)
+    if areaName == "" {
+        return nil, fmt.Errorf("You need to explore an area first")
+    }
+    config.chatf(w, "Throwing a Pokeball at %v ...\n", name)
+    location, err := config.client.GetLocationArea(ctx, areaName)
+    if errors.Is(err, internal.ErrNotFound) {
+        return nil, &internal.NotFoundError{Msg: fmt.Sprintf("There is no location area called %v", areaName)}
+    }
+    if err != nil {
+        return nil, err
+    }
+    config.currentLocation = location.Name
+    encounter, ok := location.EncounterStats(name)
+    if !ok {
+        var present []string
+        for _, encounter := range location.PokemonEncounters {
+            present = append(present, encounter.Pokemon.Name)
         }
-        rawByteBody, err := io.ReadAll(res.Body)
-        if err != nil {
-            return fmt.Errorf("Failed to readd responde body with error: %v", err)
+        err := &internal.NotFoundError{Msg: fmt.Sprintf("%v is not present in the area", name)}
+        return nil, suggest(err, name, present)
+    }
+    pokemon, err := config.client.GetPokemon(ctx, name)
+    if err != nil {
+        return nil, err
+    }
+    if err := config.pokedex.MarkSeen(pokemon.SpeciesName(), time.Now()); err != nil {
+        return nil, err
+    }
+    level := encounter.RollLevel(config.rng)
+    result := catchResult{
+        Pokemon:    pokemon.Name,
+        Level:      level,
+        Chance:     internal.CatchChance(pokemon.BaseExperience, encounter.Chance, level),
+    }
+    if !internal.TryCatch(config.rng, pokemon.BaseExperience, encounter.Chance, level) {
+        return result, nil
+    }
+    caught, err := config.pokedex.Catch(pokemon, internal.CaughtPokemon{
+        Level:      level,
+        CaughtAt:   time.Now(),
+        Location:   config.currentLocation,
+        Method:     encounter.Method,
+        MinLevel:   encounter.MinLevel,
+        MaxLevel:   encounter.MaxLevel,
+    })
+    if err != nil {
+        return nil, err
+    }
+    result.Caught = true
+    result.ID = caught.ID
+    return result, nil
+}
+
+func commandRelease(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    id, err := strconv.Atoi(args.positional[0])
+    if err != nil || id < 1 {
+        return nil, &usageError{msg: fmt.Sprintf("Invalid id %q, use the id shown by box", args.positional[0])}
+    }
+    caught, err := config.pokedex.Release(id)
+    if err != nil {
+        return nil, err
+    }
+    return releaseResult{Pokemon: caught}, nil
+}
+
+func commandBox(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    return boxResult{Pokemon: config.pokedex.BoxContents()}, nil
+}
+
+func commandInspect(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    name := args.positional[0]
+    pokemon, err := config.pokedex.Get(name)
+    if err == nil {
+        return newInspectResult(pokemon, config.pokedex.Captures(name)), nil
+    }
+    //Not a species in the dex, maybe one individual by id or nickname
+    caught, findErr := config.pokedex.FindCaught(name)
+    if findErr != nil {
+        return nil, suggest(err, name, append(config.pokedex.Names(), config.pokedex.Nicknames()...))
+    }
+    pokemon, err = config.pokedex.Get(caught.Species)
+    if err != nil {
+        return nil, err
+    }
+    return newInspectResult(pokemon, []internal.CaughtPokemon{caught}), nil
+}
+
+func commandSearch(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    items := config.pokedex.SearchItems()
+    for _, area := range config.client.CachedNames("location-area") {
+        items = append(items, internal.SearchItem{Kind: internal.SearchLocationArea, Name: area})
+    }
+    if kind, ok := args.flag("kind"); ok {
+        if !slices.Contains(searchKinds, kind) {
+            return nil, &usageError{msg: fmt.Sprintf("Unknown kind %v, use one of %v", kind, strings.Join(searchKinds, ", "))}
         }
-        err = json.Unmarshal(rawByteBody, &response)
-        if err != nil {
-            return fmt.Errorf("Failed to unmarshal rawByteBody with error: %v", err)
+        items = slices.DeleteFunc(items, func(item internal.SearchItem) bool {
+            return item.Kind != kind
+        })
+    }
+    query := strings.Join(args.positional, " ")
+    matches, suggestions := internal.Search(query, items)
+    return searchResult{
+        Query:          query,
+        Matches:        nonNil(matches),
+        Suggestions:    nonNil(suggestions[:min(len(suggestions), maxSearchSuggestions)]),
+    }, nil
+}
+
+func commandProgress(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    usage := &usageError{msg: "Usage: " + getCommands()["progress"].usage}
+    limit := 0
+    if value, ok := args.flag("limit"); ok {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 1 {
+            return nil, usage
         }
-        config.cache.Add(res.Request.URL.String(), rawByteBody)
+        limit = n
+    }
+    kind, name := "dex", "national"
+    switch {
+    case len(args.positional) == 2:
+        kind, name = args.positional[0], args.positional[1]
+    case len(args.positional) == 1 && args.positional[0] != "national":
+        return nil, usage
+    }
+    var dex internal.Dex
+    var err error
+    switch kind {
+    case "dex":
+        dex, err = config.client.GetDex(ctx, name)
+    case "region":
+        dex, err = config.client.GetRegionDex(ctx, name)
+    case "generation":
+        dex, err = config.client.GetGenerationDex(ctx, name)
+    case "version":
+        dex, err = config.client.GetVersionDex(ctx, name)
+    default:
+        return nil, usage
     }
-    
-    for _, location := range response.Results {
-        fmt.Println(location.Name)
+    if errors.Is(err, internal.ErrNotFound) {
+        return nil, &internal.NotFoundError{Msg: fmt.Sprintf("There is no %v called %v", kind, name)}
+    }
+    if err != nil {
+        return nil, err
     }
-    config.next = response.Next
-    config.current = config.prev
-    config.prev = response.Previous 
-    return nil 
+    return newProgressResult(config.pokedex.Progress(dex, config.client.CachedEncounters()), limit), nil
 }
 
-func printResponse(config *config) error {
-    res, err := http.Get("https://pokeapi.co/api/v2/location-area")
+//Adds a did you mean hint to a not found error if one of the candidates looks like what name was meant to be
+func suggest(err error, name string, candidates []string) error {
+    if !errors.Is(err, internal.ErrNotFound) {
+        return err
+    }
+    closest, ok := internal.Closest(name, candidates)
+    if !ok || closest == name {
+        return err
+    }
+    return &internal.NotFoundError{Msg: fmt.Sprintf("%v\nDid you mean %v?", err, closest)}
+}
+
+func commandNickname(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    caught, err := config.pokedex.FindCaught(args.positional[0])
     if err != nil {
-        return fmt.Errorf("Response failed with error: %v", err)
+        return nil, err
+    }
+    nickname := args.positional[1]
+    if err := checkNicknameIsNoPokemon(ctx, config, nickname); err != nil {
+        return nil, err
     }
-    if res.StatusCode > 299 {
-        return fmt.Errorf("Response failed with status code: %v\nand body: \n", res.StatusCode, res.Body)
+    previous := caught.DisplayName()
+    caught, err = config.pokedex.SetNickname(caught.ID, nickname)
+    var invalid *internal.InvalidNicknameError
+    if errors.As(err, &invalid) {
+        return nil, &usageError{msg: invalid.Msg}
     }
-    defer res.Body.Close()
-    bodyBytes, err := io.ReadAll(res.Body)
     if err != nil {
-        return fmt.Errorf("Reading of response body failed")
+        return nil, err
     }
-    fmt.Printf("%s", res.Request.URL.String())
-    fmt.Printf("%s\n", string(bodyBytes))
-    return nil
+    return nicknameResult{Previous: previous, Pokemon: caught}, nil
 }
 
-func commandExplore(config *config) error {
-    fmt.Printf("Exploring %v\n", config.additionalInput)
-    locationURL := "https://pokeapi.co/api/v2/location-area/" + config.additionalInput 
-    var response exploreResponse
-    if entry, ok := config.cache.Get(locationURL); ok {
-        err := json.Unmarshal(entry, &response)    
-        if err != nil {
-            return fmt.Errorf("Failed to unmarshal the raw byte val from cache with error: %v", err)
-        } 
-    } else {
-        res, err := http.Get(locationURL)
-        if err != nil {
-            return fmt.Errorf("Request failed with error: %v", err)
+//The Pokedex only knows the pokemon met so far, so ask the API whether the nickname names one not met yet
+func checkNicknameIsNoPokemon(ctx context.Context, config *config, nickname string) error {
+    //Numbers would find a pokemon by id, SetNickname refuses them anyway
+    if _, err := strconv.Atoi(nickname); nickname == "" || err == nil {
+        return nil
+    }
+    _, err := config.client.GetPokemon(ctx, nickname)
+    switch {
+    case err == nil:
+        return &usageError{msg: fmt.Sprintf("Nickname %v is the name of a pokemon", nickname)}
+    case errors.Is(err, internal.ErrNotFound), errors.Is(err, internal.ErrOffline):
+        return nil
+    }
+    return err
+}
+
+// Kinds search can be narrowed down to
+var searchKinds = []string{internal.SearchPokemon, internal.SearchNickname, internal.SearchMove, internal.SearchAbility, internal.SearchLocationArea}
+
+// How many near misses search lists
+const maxSearchSuggestions = 5
+
+// Base stats every pokemon has
+var statNames = []string{"hp", "attack", "defense", "special-attack", "special-defense", "speed"}
+
+func commandPokedex(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    query, err := parsePokedexQuery(args)
+    if err != nil {
+        return nil, err
+    }
+    entries, err := config.pokedex.Query(query)
+    if err != nil {
+        return nil, &usageError{msg: err.Error()}
+    }
+    config.chatf(w, "Your Pokedex:\n")
+    result := pokedexResult{
+        Pokemon:    []pokedexEntry{},
+        Seen:       config.pokedex.SeenCount(),
+        Caught:     len(config.pokedex.Names()),
+    }
+    for _, entry := range entries {
+        result.Pokemon = append(result.Pokemon, newPokedexEntry(entry))
+    }
+    return result, nil
+}
+
+//Turns the pokedex flags into a query, stat names are checked against the stats PokeAPI knows
+func parsePokedexQuery(args arguments) (internal.PokedexQuery, error) {
+    usage := &usageError{msg: "Usage: " + getCommands()["pokedex"].usage}
+    query := internal.PokedexQuery{
+        Type:       args.flags["type"],
+        Ability:    args.flags["ability"],
+        MinStats:   make(map[string]int),
+    }
+    if value, ok := args.flag("sort"); ok {
+        if !slices.Contains(internal.PokedexSortKeys, value) {
+            return query, &usageError{msg: fmt.Sprintf("Unknown sort key %v, use one of %v", value, strings.Join(internal.PokedexSortKeys, ", "))}
         }
-        defer res.Body.Close()
-        rawByteBody, err := io.ReadAll(res.Body)
-        if err != nil {
-            return fmt.Errorf("Failed to read response body with error: %v", err)
-            }
-        err = json.Unmarshal(rawByteBody, &response)
+        query.Sort = value
+    }
+    if value, ok := args.flag("reverse"); ok {
+        reverse, err := strconv.ParseBool(value)
         if err != nil {
-            return fmt.Errorf("Failed to unmarshal the response body with err: %v", err)
+            return query, usage
         }
-        config.cache.Add(res.Request.URL.String(), rawByteBody)
+        query.Reverse = reverse
     }
-    for _, encounter := range response.PokemonEncounters {
-        fmt.Printf(" - %v\n", encounter.Pokemon.Name)
+    if value, ok := args.flag("limit"); ok {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 1 {
+            return query, usage
+        }
+        query.Limit = n
+    }
+    if value, ok := args.flag("min-stat"); ok {
+        for _, condition := range strings.Split(value, ",") {
+            name, minimum, found := strings.Cut(condition, "=")
+            n, err := strconv.Atoi(minimum)
+            if !found || err != nil {
+                return query, &usageError{msg: fmt.Sprintf("Invalid stat condition %q, expected e.g. attack=80", condition)}
+            }
+            if !slices.Contains(statNames, name) {
+                return query, &usageError{msg: fmt.Sprintf("Unknown stat %v, use one of %v", name, strings.Join(statNames, ", "))}
+            }
+            query.MinStats[name] = n
+        }
     }
-    config.currentLocation = locationURL
-    return nil 
+    return query, nil
 }
 
-func commandCatch(config *config) error {
-    fmt.Printf("Throwing a Pokeball at %v ...\n", config.additionalInput)
-    pokemonURL := "https://pokeapi.co/api/v2/pokemon/" + config.additionalInput 
-    rawLocation, _ := config.cache.Get(config.currentLocation)
-    var location exploreResponse
-    err := json.Unmarshal(rawLocation, &location)
-    if err != nil {
-        return fmt.Errorf("Failed to unmarshal cached data with error: %v", err)
-    }
-    localPokemon := false
-    for _, encounter := range location.PokemonEncounters {
-        if encounter.Pokemon.Name == config.additionalInput {
-            localPokemon = true
+func commandCache(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    usage := &usageError{msg: "Usage: " + getCommands()["cache"].usage}
+    subcommand, rest := args.positional[0], args.positional[1:]
+    switch {
+    case subcommand == "status" && len(rest) == 0:
+        return cacheStatusResult{
+            Offline:    config.client.Offline(),
+            Areas:      nonNil(config.client.CachedNames("location-area")),
+            Pokemon:    nonNil(config.client.CachedNames("pokemon")),
+        }, nil
+    case subcommand == "stats" && len(rest) == 0:
+        return newCacheStatsResult(config.cache.Stats()), nil
+    case subcommand == "list" && len(rest) <= 1:
+        prefix := ""
+        if len(rest) == 1 {
+            prefix = config.cacheKey(rest[0])
+        }
+        result := cacheListResult{Keys: []string{}}
+        for _, key := range config.cache.Keys() {
+            if strings.HasPrefix(key, prefix) {
+                result.Keys = append(result.Keys, key)
+            }
         }
+        return result, nil
+    case subcommand == "evict" && len(rest) == 1:
+        key := config.cacheKey(rest[0])
+        if config.cache.Delete(key) {
+            return cacheEvictResult{Evicted: 1}, nil
+        }
+        evicted := config.cache.DeletePrefix(key)
+        if evicted == 0 {
+            return nil, &internal.NotFoundError{Msg: fmt.Sprintf("Nothing in the cache matches %v", rest[0])}
+        }
+        return cacheEvictResult{Evicted: evicted}, nil
+    case subcommand == "clear" && len(rest) == 0:
+        return cacheEvictResult{Evicted: config.cache.Clear()}, nil
+    default:
+        return nil, usage
     }
+}
 
-    if !localPokemon {
-        return fmt.Errorf("%v is not present in the area", config.additionalInput)
-    }  
-    if _, ok := config.pokedex.Entries[config.additionalInput]; ok{
-        return fmt.Errorf("%v has allready been caught", config.additionalInput)
-    } else {
-        res, err := http.Get(pokemonURL)
-        if err != nil {
-            return fmt.Errorf("Request failed with error: %v", err)
+//Turns a key relative to the base URL, e.g. pokemon/pikachu, into the full URL the cache is keyed by
+func (c *config) cacheKey(key string) string {
+    if strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+        return key
+    }
+    return c.client.BaseURL() + "/" + strings.TrimPrefix(key, "/")
+}
+
+func commandPrefetch(ctx context.Context, config *config, w io.Writer, args arguments) (any, error) {
+    usage := &usageError{msg: "Usage: " + getCommands()["prefetch"].usage}
+    workers := defaultPrefetchWorkers
+    if value, ok := args.flag("workers"); ok {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 1 {
+            return nil, usage
         }
-        defer res.Body.Close()
-        rawBytePokemon, err := io.ReadAll(res.Body)
-        if err != nil {
-            return fmt.Errorf("Failed to read response body with error: %v", err)
+        workers = n
+    }
+    prefetcher := internal.NewPrefetcher(config.client, workers, func(progress internal.PrefetchProgress) {
+        config.chatf(w, "\rFetching %v %d/%d", progress.Phase, progress.Done, progress.Total)
+        if progress.Done == progress.Total {
+            config.chatf(w, "\n")
         }
-        var pokemon internal.Pokemon
-        err = json.Unmarshal(rawBytePokemon, &pokemon)
-        if err != nil {
-            return fmt.Errorf("Failed to unmarshal with error: %v", err)
+    })
+    var summary internal.PrefetchSummary
+    var err error
+    switch kind, target := args.positional[0], args.positional[1]; kind {
+    case "region":
+        summary, err = prefetcher.Region(ctx, target)
+    case "areas":
+        first, last, ok := parseRange(target)
+        if !ok {
+            return nil, usage
         }
-        config.pokedex.Add(pokemon)
+        summary, err = prefetcher.AreaRange(ctx, first, last)
+    default:
+        return nil, usage
+    }
+    if errors.Is(err, internal.ErrNotFound) {
+        return nil, &internal.NotFoundError{Msg: fmt.Sprintf("There is no region called %v", args.positional[1])}
     }
-    return nil 
+    //An interrupted run still reports what it got done, a rerun resumes from there
+    return prefetchResult(summary), err
 }
 
-func commandInspect(config *config) error {
-    pokemon, err := config.pokedex.Get(config.additionalInput)
+//Parses an inclusive range like 1-100, a single number is a range of one
+func parseRange(value string) (int, int, bool) {
+    firstText, lastText, found := strings.Cut(value, "-")
+    if !found {
+        lastText = firstText
+    }
+    first, err := strconv.Atoi(firstText)
     if err != nil {
-        return err
+        return 0, 0, false
     }
-    fmt.Printf("%v: %v\n", "Name", pokemon.Name)
-    fmt.Printf("%v: %v\n", "Height", pokemon.Height)
-    fmt.Printf("%v: %v\n", "Weight", pokemon.Weight)
-    fmt.Printf("%v:\n", "Stats")
-    for _, stat := range pokemon.Stats {
-        fmt.Printf("    - %v: %v\n", stat.Stat.Name, stat.BaseStat)
+    last, err := strconv.Atoi(lastText)
+    if err != nil || first < 1 || last < first {
+        return 0, 0, false
     }
-    fmt.Printf("%v:\n", "Types")
-    for _, pokeType := range pokemon.Types {
-        fmt.Printf("    - %v\n", pokeType.Type.Name)
+    return first, last, true
+}
+
+//Keeps empty lists as [] instead of null in JSON output
+func nonNil[T any](list []T) []T {
+    if list == nil {
+        return []T{}
     }
-    return nil
+    return list
 }
 
-func commandPokedex(config *config) error {
-    fmt.Println("Your Pokedex:")
-    return config.pokedex.Show() 
+//Runs a single command given on the command line and returns the exit code for it
+func runOnce(ctx context.Context, config *config, commands map[string]cliCommand, tokens []string, w io.Writer, errW io.Writer) int {
+    err := runTokens(ctx, config, commands, w, tokens)
+    if err == nil || errors.Is(err, errExit) {
+        return exitOK
+    }
+    fmt.Fprintf(errW, "%v\n", err)
+    var usage *usageError
+    switch {
+    case errors.As(err, &usage):
+        return exitUsage
+    case errors.Is(err, context.Canceled):
+        return exitInterrupted
+    case errors.Is(err, internal.ErrNotFound):
+        return exitNotFound
+    default:
+        return exitError
+    }
+}
+
+//Default location of the save file, falls back to the working directory if there is no user config dir
+func defaultSavePath() string {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "pokedex.json"
+    }
+    return filepath.Join(dir, "pokedexcli", "pokedex.json")
+}
+
+//Default location of the REPL history, next to the save file
+func defaultHistoryPath() string {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(dir, "pokedexcli", "history")
+}
+
+//Default directory of the on-disk cache, empty if there is no user cache dir
+func defaultCacheDir() string {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(dir, "pokedexcli")
 }
 
 func main() {
-    scanner := bufio.NewScanner(os.Stdin)
+    savePath := flag.String("save", defaultSavePath(), "Path of the Pokedex save file")
+    cacheTTL := flag.Int("cache-ttl", 60, "Seconds a cached API response stays valid")
+    cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory of the on-disk cache, empty disables it")
+    cacheMaxBytes := flag.Int64("cache-max-bytes", 50<<20, "Maximum size of the on-disk cache in bytes, 0 means unbounded")
+    baseURL := flag.String("base-url", os.Getenv("POKEAPI_BASE_URL"), "PokeAPI base URL, defaults to $POKEAPI_BASE_URL or https://pokeapi.co/api/v2")
+    cacheMemBytes := flag.Int64("cache-mem-bytes", 16<<20, "Maximum size of the in-memory cache in bytes, 0 means unbounded")
+    cacheMaxEntries := flag.Int("cache-max-entries", 0, "Maximum number of entries in the in-memory cache, 0 means unbounded")
+    maxRetries := flag.Int("max-retries", 3, "How often a failed PokeAPI request is retried")
+    requestsPerSecond := flag.Float64("rps", 10, "Maximum PokeAPI requests per second, 0 means unlimited")
+    offline := flag.Bool("offline", false, "Answer from the cache only and never touch the network")
+    timeout := flag.Duration("timeout", 30*time.Second, "Time limit for a single command, 0 means no limit")
+    historyPath := flag.String("history", defaultHistoryPath(), "Path of the REPL history file, empty keeps no history")
+    output := flag.String("output", outputText, "Output format of the commands: text, json or yaml")
+    seed := flag.Uint64("seed", 0, "Seed for catch attempts, 0 picks a random one")
+    flag.Parse()
+
+    if !validOutputFormat(*output) {
+        fmt.Fprintf(os.Stderr, "Unknown output format %v, expected text, json or yaml\n", *output)
+        os.Exit(exitUsage)
+    }
     config := config{
-        prev: "",
-        next: "",
+        timeout:        *timeout,
+        defaultOutput:  *output,
+        output:         *output,
+    }
+    if *seed == 0 {
+        *seed = rand.Uint64()
     }
+    config.rng = rand.New(rand.NewPCG(*seed, *seed))
     // .NewCache returns pointer to the created cache!
-    config.cache = internal.NewCache(60)
-    config.pokedex = internal.NewPokedex()
-    commands := getCommands()
-    for {
-        fmt.Printf("pokedex > ")
-        if scanner.Scan() {
-            input := strings.Split(scanner.Text(), " ")
-            if len(input) > 1{
-                config.additionalInput = strings.TrimSpace(input[1])
-            }
-            if _, ok := commands[input[0]]; ok {
-                if err := commands[input[0]].callback(&config); err != nil {
-                    fmt.Printf("%v\n", err)
-                }
-            } else {
-                fmt.Printf("%v is not a valid command\n", scanner.Text())
-            }    
+    config.cache = internal.NewCache(*cacheTTL)
+    config.cache.SetLimits(*cacheMemBytes, *cacheMaxEntries)
+    if *cacheDir != "" {
+        if err := config.cache.Persist(*cacheDir, *cacheMaxBytes); err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
         }
     }
+    pokedex, err := internal.LoadPokedex(*savePath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+    }
+    config.pokedex = pokedex
+    config.client = internal.NewClient(config.cache)
+    config.client.SetTransport(internal.NewRetryTransport(nil, *maxRetries, *requestsPerSecond))
+    config.client.SetOffline(*offline)
+    if *baseURL != "" {
+        if err := config.client.SetBaseURL(*baseURL); err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(1)
+        }
+    }
+    if flag.NArg() > 0 {
+        ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+        code := runOnce(ctx, &config, getCommands(), flag.Args(), os.Stdout, os.Stderr)
+        stop()
+        config.cache.Close()
+        os.Exit(code)
+    }
+    config.interactive = true
+    repl := newREPL(&config, os.Stdin, os.Stdout, os.Stderr)
+    repl.historyPath = *historyPath
+    interrupts := make(chan os.Signal, 1)
+    signal.Notify(interrupts, os.Interrupt)
+    repl.interrupts = interrupts
+    err = repl.run()
+    config.cache.Close()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(exitError)
+    }
 }
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGeneral00/pokedexcli/internal"
+	"github.com/TheGeneral00/pokedexcli/internal/fakeapi"
+)
+
+// A config wired up like main does it, but against the fake PokeAPI and a save file in a temporary directory
+type testEnv struct {
+    config      *config
+    server      *fakeapi.Server
+    savePath    string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+    t.Helper()
+    server := fakeapi.NewServer()
+    t.Cleanup(server.Close)
+    cache := internal.NewCache(60)
+    t.Cleanup(func() {
+        cache.Close()
+    })
+    savePath := filepath.Join(t.TempDir(), "pokedex.json")
+    pokedex, err := internal.LoadPokedex(savePath)
+    if err != nil {
+        t.Fatalf("LoadPokedex failed: %v", err)
+    }
+    client := internal.NewClient(cache)
+    if err := client.SetBaseURL(server.BaseURL()); err != nil {
+        t.Fatalf("SetBaseURL failed: %v", err)
+    }
+    return &testEnv{
+        config: &config{
+            cache:          cache,
+            pokedex:        pokedex,
+            client:         client,
+            rng:            rand.New(rand.NewPCG(1, 1)),
+            timeout:        5 * time.Second,
+            defaultOutput:  outputText,
+            output:         outputText,
+        },
+        server:     server,
+        savePath:   savePath,
+    }
+}
+
+//Runs line like the REPL does and returns what it printed
+func (e *testEnv) run(line string) (string, error) {
+    var out bytes.Buffer
+    err := runLine(context.Background(), e.config, getCommands(), &out, line)
+    return out.String(), err
+}
+
+func (e *testEnv) mustRun(t *testing.T, line string) string {
+    t.Helper()
+    out, err := e.run(line)
+    if err != nil {
+        t.Fatalf("%v failed: %v", line, err)
+    }
+    return out
+}
+
+//Number of requests the fake server got for a path below the API root, e.g. pokemon/pikachu
+func (e *testEnv) requests(path string) int {
+    return e.server.Requests("/api/v2/" + path)
+}
+
+//Catches name in the current location, retrying escapes until the rng lets it through
+func (e *testEnv) catch(t *testing.T, name string) string {
+    t.Helper()
+    for i := 0; i < 50; i++ {
+        out := e.mustRun(t, "catch "+name)
+        if strings.HasPrefix(out, "You caught") {
+            return out
+        }
+    }
+    t.Fatalf("could not catch %v in 50 attempts", name)
+    return ""
+}
+
+func TestMapPagesThroughLocationAreas(t *testing.T) {
+    env := newTestEnv(t)
+    for id := 100; id < 122; id++ {
+        data := fmt.Sprintf(`{"id": %d, "name": "test-area-%d"}`, id, id)
+        if err := env.server.Add("location-area", fmt.Sprintf("test-area-%d", id), []byte(data)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    out := env.mustRun(t, "map")
+    lines := strings.Split(strings.TrimSpace(out), "\n")
+    if len(lines) != internal.LocationAreaPageSize || lines[0] != "canalave-city-area" {
+        t.Fatalf("first page = %q, want %d areas starting with canalave-city-area", lines, internal.LocationAreaPageSize)
+    }
+    if env.config.page != 1 || !env.config.hasNext {
+        t.Fatalf("page = %d, hasNext = %v after the first map, want 1, true", env.config.page, env.config.hasNext)
+    }
+
+    out = env.mustRun(t, "map")
+    if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 5 || lines[4] != "test-area-121" {
+        t.Fatalf("second page = %q, want the last 5 areas", lines)
+    }
+    if env.config.page != 2 || env.config.hasNext {
+        t.Fatalf("page = %d, hasNext = %v after the second map, want 2, false", env.config.page, env.config.hasNext)
+    }
+    if _, err := env.run("map"); err == nil {
+        t.Fatal("map past the last page succeeded")
+    }
+
+    out = env.mustRun(t, "mapb")
+    if !strings.HasPrefix(out, "canalave-city-area\n") || env.config.page != 1 {
+        t.Fatalf("mapb printed %q on page %d, want the first page again", out, env.config.page)
+    }
+    if got := env.requests("location-area"); got != 2 {
+        t.Fatalf("location-area listing was requested %d times, want 2 as mapb answers from the cache", got)
+    }
+}
+
+func TestMapBOnFirstPage(t *testing.T) {
+    env := newTestEnv(t)
+    if _, err := env.run("mapb"); err == nil {
+        t.Fatal("mapb before any map succeeded")
+    }
+    env.mustRun(t, "map")
+    if _, err := env.run("mapb"); err == nil {
+        t.Fatal("mapb on the first page succeeded")
+    }
+    if env.config.page != 1 {
+        t.Fatalf("page = %d after a failed mapb, want 1", env.config.page)
+    }
+}
+
+func TestExploreSetsLocationAndCachesArea(t *testing.T) {
+    env := newTestEnv(t)
+
+    out := env.mustRun(t, "explore viridian-forest-area")
+    if out != " - pikachu\n - caterpie\n" {
+        t.Fatalf("explore printed %q", out)
+    }
+    if env.config.currentLocation != "viridian-forest-area" {
+        t.Fatalf("currentLocation = %q, want viridian-forest-area", env.config.currentLocation)
+    }
+    if _, ok := env.config.cache.Get(env.server.BaseURL() + "/location-area/viridian-forest-area"); !ok {
+        t.Fatal("explored area is not in the cache")
+    }
+
+    env.mustRun(t, "explore viridian-forest-area")
+    if got := env.requests("location-area/viridian-forest-area"); got != 1 {
+        t.Fatalf("area was requested %d times, want 1 as the second explore is a cache hit", got)
+    }
+}
+
+func TestExploreUnknownArea(t *testing.T) {
+    env := newTestEnv(t)
+    env.mustRun(t, "explore pallet-town-area")
+
+    _, err := env.run("explore bogus-area")
+    if !errors.Is(err, internal.ErrNotFound) {
+        t.Fatalf("explore of an unknown area returned %v, want ErrNotFound", err)
+    }
+    if env.config.currentLocation != "pallet-town-area" {
+        t.Fatalf("currentLocation = %q after the failed explore, want pallet-town-area", env.config.currentLocation)
+    }
+}
+
+func TestCatchStoresIndividualAndSpecies(t *testing.T) {
+    env := newTestEnv(t)
+    env.mustRun(t, "explore pallet-town-area")
+
+    out := env.catch(t, "magikarp")
+    if !strings.Contains(out, "added to your box with id 1") {
+        t.Fatalf("catch printed %q", out)
+    }
+    box := env.config.pokedex.BoxContents()
+    if len(box) != 1 || box[0].Species != "magikarp" || box[0].Location != "pallet-town-area" || box[0].Level == 0 {
+        t.Fatalf("box = %+v, want one levelled magikarp from pallet-town-area", box)
+    }
+    if _, ok := env.config.pokedex.Entries["magikarp"]; !ok {
+        t.Fatal("magikarp is not in the Pokedex entries")
+    }
+
+    env.catch(t, "magikarp")
+    if got := len(env.config.pokedex.BoxContents()); got != 2 {
+        t.Fatalf("box holds %d pokemon after catching a second magikarp, want 2", got)
+    }
+    if got := len(env.config.pokedex.Entries); got != 1 {
+        t.Fatalf("Pokedex has %d species, want 1", got)
+    }
+    if got := env.requests("pokemon/magikarp"); got != 1 {
+        t.Fatalf("magikarp was requested %d times, want 1", got)
+    }
+
+    saved, err := internal.LoadPokedex(env.savePath)
+    if err != nil {
+        t.Fatalf("LoadPokedex failed: %v", err)
+    }
+    if got := len(saved.BoxContents()); got != 2 {
+        t.Fatalf("save file holds %d pokemon, want 2", got)
+    }
+}
+
+func TestCatchNeedsAnArea(t *testing.T) {
+    env := newTestEnv(t)
+    if _, err := env.run("catch magikarp"); err == nil {
+        t.Fatal("catch without exploring succeeded")
+    }
+
+    env.mustRun(t, "explore pallet-town-area")
+    if _, err := env.run("catch pikachu"); !errors.Is(err, internal.ErrNotFound) {
+        t.Fatalf("catch of a pokemon not in the area returned %v, want ErrNotFound", err)
+    }
+    if _, err := env.run("catch pikachu --area=bogus"); !errors.Is(err, internal.ErrNotFound) {
+        t.Fatalf("catch in an unknown area returned %v, want ErrNotFound", err)
+    }
+    if env.config.currentLocation != "pallet-town-area" {
+        t.Fatalf("currentLocation = %q after a catch in an unknown area, want pallet-town-area", env.config.currentLocation)
+    }
+    if len(env.config.pokedex.BoxContents()) != 0 {
+        t.Fatal("failed catches put pokemon into the box")
+    }
+
+    env.catch(t, "pikachu --area=viridian-forest-area")
+    if env.config.currentLocation != "viridian-forest-area" {
+        t.Fatalf("currentLocation = %q after catching with --area, want viridian-forest-area", env.config.currentLocation)
+    }
+}
+
+func TestInspect(t *testing.T) {
+    env := newTestEnv(t)
+    if _, err := env.run("inspect magikarp"); !errors.Is(err, internal.ErrNotFound) {
+        t.Fatalf("inspect before catching returned %v, want ErrNotFound", err)
+    }
+
+    env.mustRun(t, "explore pallet-town-area")
+    env.catch(t, "magikarp")
+    requests := env.requests("pokemon/magikarp")
+    out := env.mustRun(t, "inspect magikarp")
+    for _, want := range []string{"Name: magikarp\n", "Height: 9\n", "Weight: 100\n", "    - water\n"} {
+        if !strings.Contains(out, want) {
+            t.Fatalf("inspect printed %q, missing %q", out, want)
+        }
+    }
+    if got := env.requests("pokemon/magikarp"); got != requests {
+        t.Fatal("inspect went to the network instead of the Pokedex")
+    }
+}
+
+func TestPokedex(t *testing.T) {
+    env := newTestEnv(t)
+    if out := env.mustRun(t, "pokedex"); !strings.HasPrefix(out, "No Entries\n") {
+        t.Fatalf("empty pokedex printed %q", out)
+    }
+
+    env.mustRun(t, "explore viridian-forest-area")
+    env.catch(t, "caterpie")
+    env.catch(t, "pikachu")
+    out := env.mustRun(t, "pokedex")
+    lines := strings.Split(strings.TrimSpace(out), "\n")
+    if len(lines) != 4 || !strings.HasPrefix(lines[1], "10 ") || !strings.HasPrefix(lines[2], "25 ") {
+        t.Fatalf("pokedex printed %q, want a header, caterpie and pikachu in id order and a summary", out)
+    }
+    if !strings.Contains(lines[3], "Caught: 2") {
+        t.Fatalf("pokedex summary = %q, want 2 caught", lines[3])
+    }
+}
+
+func TestNicknameMustStayReachable(t *testing.T) {
+    env := newTestEnv(t)
+    env.mustRun(t, "explore viridian-forest-area")
+    env.catch(t, "caterpie")
+    env.catch(t, "pikachu")
+    env.mustRun(t, "nickname caterpie Bob")
+
+    for _, line := range []string{
+        "nickname caterpie 1",
+        "nickname caterpie pikachu",
+        "nickname caterpie staryu",
+        "nickname pikachu Bob",
+    } {
+        var usage *usageError
+        if _, err := env.run(line); !errors.As(err, &usage) {
+            t.Fatalf("%v returned %v, want a usage error", line, err)
+        }
+    }
+    box := env.config.pokedex.BoxContents()
+    if box[0].Nickname != "Bob" || box[1].Nickname != "" {
+        t.Fatalf("box = %+v after refused nicknames, want only caterpie called Bob", box)
+    }
+
+    if out := env.mustRun(t, "nickname pikachu Sparky"); out != "pikachu #2 is now called Sparky\n" {
+        t.Fatalf("nickname printed %q", out)
+    }
+    if out := env.mustRun(t, "nickname Bob Rob"); out != "Bob #1 is now called Rob\n" {
+        t.Fatalf("nickname printed %q", out)
+    }
+}
+
+func TestCacheStatsCountOnlyCommandLookups(t *testing.T) {
+    env := newTestEnv(t)
+    env.mustRun(t, "explore 3")
+    if stats := env.config.cache.Stats(); stats.Hits != 0 || stats.Misses != 1 {
+        t.Fatalf("stats = %+v after exploring a cold cache, want 0 hits and 1 miss", stats)
+    }
+    env.mustRun(t, "explore viridian-forest-area")
+    if stats := env.config.cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+        t.Fatalf("stats = %+v after exploring the area again, want 1 hit and 1 miss", stats)
+    }
+
+    env.mustRun(t, "progress region kanto")
+    env.mustRun(t, "prefetch areas 3-3")
+    stats := env.config.cache.Stats()
+    if stats.Hits != 1 {
+        t.Fatalf("stats = %+v after progress and prefetch of cached areas, want still 1 hit", stats)
+    }
+}
+
+// rand.Source that makes every catch escape
+type escapeSource struct{}
+
+func (escapeSource) Uint64() uint64 {
+    return ^uint64(0)
+}
+
+func TestProgressCountsFormsAsTheirSpecies(t *testing.T) {
+    env := newTestEnv(t)
+    for _, resource := range []struct {
+        kind, name, data string
+    }{
+        {"pokedex", "forms", `{"id": 90, "name": "forms", "pokemon_entries": [
+            {"entry_number": 1, "pokemon_species": {"name": "bulbasaur"}},
+            {"entry_number": 25, "pokemon_species": {"name": "pikachu"}},
+            {"entry_number": 386, "pokemon_species": {"name": "deoxys"}}]}`},
+        {"pokemon", "deoxys-normal", `{"id": 386, "name": "deoxys-normal", "base_experience": 270, "species": {"name": "deoxys"}}`},
+        {"location-area", "sky-pillar-area", `{"id": 40, "name": "sky-pillar-area", "pokemon_encounters": [
+            {"pokemon": {"name": "deoxys-normal"}, "version_details": [{"encounter_details": [
+                {"min_level": 50, "max_level": 50, "chance": 100, "method": {"name": "walk"}}]}]}]}`},
+    } {
+        if err := env.server.Add(resource.kind, resource.name, []byte(resource.data)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    env.mustRun(t, "explore viridian-forest-area")
+    env.catch(t, "pikachu")
+    env.mustRun(t, "explore sky-pillar-area")
+    env.config.rng = rand.New(escapeSource{})
+    if out := env.mustRun(t, "catch deoxys-normal"); !strings.Contains(out, "escaped") {
+        t.Fatalf("catch printed %q, want deoxys-normal to escape", out)
+    }
+
+    out := env.mustRun(t, "progress dex forms")
+    want := "forms dex: 3 species\n" +
+        "Seen:   2/3 (66.7%)\n" +
+        "Caught: 1/3 (33.3%)\n" +
+        "Missing (2):\n" +
+        " #001  bulbasaur        not in any cached area\n" +
+        " #386  deoxys     seen  sky-pillar-area\n"
+    if out != want {
+        t.Fatalf("progress printed\n%v\nwant\n%v", out, want)
+    }
+}
+
+func TestFailedSavesLeaveTheBoxAlone(t *testing.T) {
+    env := newTestEnv(t)
+    env.mustRun(t, "explore viridian-forest-area")
+    env.catch(t, "pikachu")
+    //Seeing caterpie now keeps the failing save from stopping the catch before the throw
+    env.config.rng = rand.New(escapeSource{})
+    env.mustRun(t, "catch caterpie")
+    env.config.rng = rand.New(rand.NewPCG(1, 1))
+    //A directory in place of the save file makes every later save fail
+    if err := os.Remove(env.savePath); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Mkdir(env.savePath, 0o755); err != nil {
+        t.Fatal(err)
+    }
+
+    for line, want := range map[string]string{
+        "catch caterpie":       "caterpie was not added to your box",
+        "release 1":            "pikachu #1 stays in your box",
+        "nickname 1 Sparky":    "pikachu #1 keeps its name",
+    } {
+        var err error
+        //Escapes do not save, keep throwing until one catches
+        for i := 0; i < 50 && err == nil; i++ {
+            _, err = env.run(line)
+        }
+        if err == nil || !strings.Contains(err.Error(), want) {
+            t.Fatalf("%v returned %v, want an error saying %v", line, err, want)
+        }
+    }
+    box := env.config.pokedex.BoxContents()
+    if len(box) != 1 || box[0].Species != "pikachu" || box[0].Nickname != "" {
+        t.Fatalf("box = %+v after failed saves, want the unnamed pikachu only", box)
+    }
+    if names := env.config.pokedex.Names(); len(names) != 1 {
+        t.Fatalf("dex holds %v after a catch that was not saved, want pikachu only", names)
+    }
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+    outputText = "text"
+    outputJSON = "json"
+    outputYAML = "yaml"
+)
+
+// Flags every command accepts on top of its own
+var globalFlags = []string{"output", "json"}
+
+// Global flags that take a value, see cliCommand.valueFlags
+var globalValueFlags = []string{"output"}
+
+// Command results implement this to control how they look in text mode
+type textRenderer interface {
+    renderText(w io.Writer)
+}
+
+func validOutputFormat(format string) bool {
+    return format == outputText || format == outputJSON || format == outputYAML
+}
+
+//Picks the output format for one command, --json and --output override the global default
+func resolveOutputFormat(defaultFormat string, args arguments) (string, error) {
+    if asJSON, ok := args.flag("json"); ok && asJSON == "true" {
+        return outputJSON, nil
+    }
+    format, ok := args.flag("output")
+    if !ok {
+        return defaultFormat, nil
+    }
+    if !validOutputFormat(format) {
+        return "", &usageError{msg: fmt.Sprintf("Unknown output format %v, expected text, json or yaml", format)}
+    }
+    return format, nil
+}
+
+//Writes result to w in the given format. A nil result prints nothing
+func render(w io.Writer, format string, result any) error {
+    if result == nil {
+        return nil
+    }
+    switch format {
+    case outputJSON:
+        data, err := json.MarshalIndent(result, "", "  ")
+        if err != nil {
+            return fmt.Errorf("Failed to marshal the result with error: %v", err)
+        }
+        fmt.Fprintln(w, string(data))
+    case outputYAML:
+        // Round trip through JSON so the YAML keys follow the json tags
+        data, err := json.Marshal(result)
+        if err != nil {
+            return fmt.Errorf("Failed to marshal the result with error: %v", err)
+        }
+        var generic any
+        if err := json.Unmarshal(data, &generic); err != nil {
+            return fmt.Errorf("Failed to unmarshal the result with error: %v", err)
+        }
+        encoder := yaml.NewEncoder(w)
+        encoder.SetIndent(2)
+        if err := encoder.Encode(generic); err != nil {
+            return fmt.Errorf("Failed to marshal the result to yaml with error: %v", err)
+        }
+        return encoder.Close()
+    default:
+        if renderer, ok := result.(textRenderer); ok {
+            renderer.renderText(w)
+        } else {
+            fmt.Fprintf(w, "%v\n", result)
+        }
+    }
+    return nil
+}
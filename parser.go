@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Input that does not fit what a command expects
+type usageError struct {
+    msg string
+}
+
+func (e *usageError) Error() string {
+    return e.msg
+}
+
+// Parsed input of a single command, without the command name itself
+type arguments struct {
+    positional []string
+    flags map[string]string
+}
+
+//Splits a line into tokens on runs of whitespace. Single and double quotes group words, a backslash escapes the next character outside of single quotes
+func tokenize(line string) ([]string, error) {
+    var tokens []string
+    var current strings.Builder
+    inToken := false
+    var quote rune
+    escaped := false
+    for _, r := range line {
+        switch {
+        case escaped:
+            current.WriteRune(r)
+            escaped = false
+        case r == '\\' && quote != '\'':
+            escaped = true
+            inToken = true
+        case quote != 0:
+            if r == quote {
+                quote = 0
+            } else {
+                current.WriteRune(r)
+            }
+        case r == '"' || r == '\'':
+            quote = r
+            inToken = true
+        case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+            if inToken {
+                tokens = append(tokens, current.String())
+                current.Reset()
+                inToken = false
+            }
+        default:
+            current.WriteRune(r)
+            inToken = true
+        }
+    }
+    if escaped {
+        return nil, &usageError{msg: "Input ends with an unfinished escape"}
+    }
+    if quote != 0 {
+        return nil, &usageError{msg: fmt.Sprintf("Input has an unterminated %c quote", quote)}
+    }
+    if inToken {
+        tokens = append(tokens, current.String())
+    }
+    return tokens, nil
+}
+
+//Sorts tokens into flags (--name=value, or --name meaning true) and positional arguments. Everything after a bare -- is positional.
+//Flags named in valueFlags may also be written as --name value
+func parseArguments(tokens []string, valueFlags []string) arguments {
+    args := arguments{
+        flags: make(map[string]string),
+    }
+    for i := 0; i < len(tokens); i++ {
+        token := tokens[i]
+        if token == "--" {
+            args.positional = append(args.positional, tokens[i+1:]...)
+            break
+        }
+        if !strings.HasPrefix(token, "--") || len(token) == 2 {
+            args.positional = append(args.positional, token)
+            continue
+        }
+        name, value, found := strings.Cut(token[2:], "=")
+        if !found && slices.Contains(valueFlags, name) && i+1 < len(tokens) {
+            i++
+            value = tokens[i]
+        } else if !found {
+            value = "true"
+        }
+        args.flags[name] = value
+    }
+    return args
+}
+
+func (a arguments) flag(name string) (string, bool) {
+    value, ok := a.flags[name]
+    return value, ok
+}
+
+//Checks the arguments against what the command declares, the error carries the usage line
+func (c cliCommand) validate(args arguments) error {
+    if len(args.positional) < c.minArgs || (c.maxArgs >= 0 && len(args.positional) > c.maxArgs) {
+        return &usageError{msg: "Usage: " + c.usage}
+    }
+    for name := range args.flags {
+        known := false
+        for _, flag := range append(c.flags, globalFlags...) {
+            if flag == name {
+                known = true
+                break
+            }
+        }
+        if !known {
+            return &usageError{msg: fmt.Sprintf("Unknown flag --%v\nUsage: %v", name, c.usage)}
+        }
+    }
+    return nil
+}
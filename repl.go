@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peterh/liner"
+)
+
+// Returned by commandExit to end the run loop
+var errExit = errors.New("exit")
+
+// Read-eval-print loop over arbitrary streams, so it can be embedded and driven from tests
+type repl struct {
+    config *config
+    commands map[string]cliCommand
+    in io.Reader
+    out io.Writer
+    errOut io.Writer
+    prompt string
+    // History file of the terminal line editor, empty keeps the history in memory only
+    historyPath string
+    // Each value cancels the command that is running at the time, nil means commands cannot be interrupted
+    interrupts <-chan os.Signal
+}
+
+func newREPL(config *config, in io.Reader, out io.Writer, errOut io.Writer) *repl {
+    return &repl{
+        config: config,
+        commands: getCommands(),
+        in: in,
+        out: out,
+        errOut: errOut,
+        prompt: "pokedex > ",
+    }
+}
+
+//Reads and runs commands until exit or the end of the input, which behaves like exit
+func (r *repl) run() error {
+    lines := r.lineReader()
+    defer func() {
+        if err := lines.close(); err != nil {
+            fmt.Fprintf(r.errOut, "%v\n", err)
+        }
+    }()
+    for {
+        line, err := lines.readLine(r.prompt)
+        if err == io.EOF {
+            fmt.Fprintln(r.out)
+            // Nothing left to read, so a failing exit has to end the loop as well
+            if err := r.runCommand("exit"); !errors.Is(err, errExit) {
+                return err
+            }
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("Failed to read input with error: %v", err)
+        }
+        err = r.runCommand(line)
+        if errors.Is(err, errExit) {
+            return nil
+        }
+        if err != nil {
+            fmt.Fprintf(r.errOut, "%v\n", err)
+        }
+    }
+}
+
+//Runs one line with its own context, cancelled by an interrupt. runTokens adds the timeout
+func (r *repl) runCommand(line string) error {
+    // Interrupts from while we were waiting at the prompt are not meant for this command
+    for len(r.interrupts) > 0 {
+        <-r.interrupts
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    done := make(chan struct{})
+    defer close(done)
+    go func() {
+        select {
+        case <-r.interrupts:
+            cancel()
+        case <-done:
+        }
+    }()
+    return runLine(ctx, r.config, r.commands, r.out, line)
+}
+
+//Uses the line editor when reading from an interactive terminal, plain lines otherwise
+func (r *repl) lineReader() lineReader {
+    if file, ok := r.in.(*os.File); ok && file == os.Stdin && isTerminal(file) && liner.TerminalSupported() {
+        return newTerminalLineReader(r.historyPath, r.complete)
+    }
+    return &scannerLineReader{
+        scanner: bufio.NewScanner(r.in),
+        out: r.out,
+    }
+}
+
+//Parses and runs a single line of input
+func runLine(ctx context.Context, config *config, commands map[string]cliCommand, w io.Writer, line string) error {
+    tokens, err := tokenize(line)
+    if err != nil {
+        return err
+    }
+    return runTokens(ctx, config, commands, w, tokens)
+}
+
+//Runs an already tokenized command, e.g. straight from the program arguments
+func runTokens(ctx context.Context, config *config, commands map[string]cliCommand, w io.Writer, tokens []string) error {
+    if len(tokens) == 0 {
+        return nil
+    }
+    command, ok := commands[tokens[0]]
+    if !ok {
+        return &usageError{msg: fmt.Sprintf("%v is not a valid command", tokens[0])}
+    }
+    args := parseArguments(tokens[1:], append(command.valueFlags, globalValueFlags...))
+    if err := command.validate(args); err != nil {
+        return err
+    }
+    format, err := resolveOutputFormat(config.defaultOutput, args)
+    if err != nil {
+        return err
+    }
+    config.output = format
+    ctx, cancel := config.commandContext(ctx, command)
+    defer cancel()
+    result, err := command.callback(ctx, config, w, args)
+    if err != nil {
+        //Commands may hand back what they got done before they failed
+        if result != nil {
+            render(w, format, result)
+        }
+        return commandError(ctx, config, err)
+    }
+    return render(w, format, result)
+}
+
+// Command that was cut short by an interrupt or the timeout, unwraps to the context error
+type abortedError struct {
+    msg string
+    cause error
+}
+
+func (e *abortedError) Error() string {
+    return e.msg
+}
+
+func (e *abortedError) Unwrap() error {
+    return e.cause
+}
+
+//Replaces whatever a cancelled or timed out request bubbled up with a message that says what happened
+func commandError(ctx context.Context, config *config, err error) error {
+    switch {
+    case errors.Is(ctx.Err(), context.Canceled):
+        return &abortedError{msg: "Command interrupted", cause: ctx.Err()}
+    case errors.Is(ctx.Err(), context.DeadlineExceeded):
+        return &abortedError{msg: fmt.Sprintf("Command timed out after %v", config.timeout), cause: ctx.Err()}
+    }
+    return err
+}
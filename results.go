@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TheGeneral00/pokedexcli/internal"
+)
+
+type helpResult struct {
+    Commands []helpEntry `json:"commands"`
+}
+
+type helpEntry struct {
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    Usage       string `json:"usage"`
+}
+
+func (r helpResult) renderText(w io.Writer) {
+    fmt.Fprint(w, "Welcome to the Pokedex!\n\nUsage:\n\n")
+    for _, command := range r.Commands {
+        fmt.Fprintln(w, command.Name, ":", command.Description)
+        fmt.Fprintln(w, "    usage:", command.Usage)
+    }
+    fmt.Fprint(w, "\n\n")
+}
+
+type locationPageResult struct {
+    Page        int      `json:"page"`
+    Locations   []string `json:"locations"`
+}
+
+func (r locationPageResult) renderText(w io.Writer) {
+    for _, location := range r.Locations {
+        fmt.Fprintln(w, location)
+    }
+}
+
+// Unparsed API response, embedded as is in JSON output
+type rawResponse []byte
+
+func (r rawResponse) MarshalJSON() ([]byte, error) {
+    return r, nil
+}
+
+func (r rawResponse) renderText(w io.Writer) {
+    fmt.Fprintf(w, "%s\n", []byte(r))
+}
+
+type exploreResult struct {
+    Area    string   `json:"area"`
+    Pokemon []string `json:"pokemon"`
+}
+
+func (r exploreResult) renderText(w io.Writer) {
+    for _, pokemon := range r.Pokemon {
+        fmt.Fprintf(w, " - %v\n", pokemon)
+    }
+}
+
+type catchResult struct {
+    ID      int     `json:"id,omitempty"`
+    Pokemon string  `json:"pokemon"`
+    Level   int     `json:"level"`
+    Chance  float64 `json:"chance"`
+    Caught  bool    `json:"caught"`
+}
+
+func (r catchResult) renderText(w io.Writer) {
+    if r.Caught {
+        fmt.Fprintf(w, "You caught a %v (level %v). It was added to your box with id %v\n", r.Pokemon, r.Level, r.ID)
+    } else {
+        fmt.Fprintf(w, "%v (level %v) escaped!\n", r.Pokemon, r.Level)
+    }
+}
+
+type inspectResult struct {
+    ID              int         `json:"id"`
+    Name            string      `json:"name"`
+    Height          int         `json:"height"`
+    Weight          int         `json:"weight"`
+    BaseExperience  int         `json:"base_experience"`
+    Stats           []statValue `json:"stats"`
+    Types           []string    `json:"types"`
+    Abilities       []string    `json:"abilities"`
+    Captures        []internal.CaughtPokemon `json:"captures"`
+}
+
+type statValue struct {
+    Name    string `json:"name"`
+    Value   int    `json:"value"`
+}
+
+func newInspectResult(pokemon internal.Pokemon, captures []internal.CaughtPokemon) inspectResult {
+    result := inspectResult{
+        ID:             pokemon.ID,
+        Name:           pokemon.Name,
+        Height:         pokemon.Height,
+        Weight:         pokemon.Weight,
+        BaseExperience: pokemon.BaseExperience,
+        Stats:          []statValue{},
+        Types:          []string{},
+        Abilities:      []string{},
+        Captures:       captures,
+    }
+    for _, stat := range pokemon.Stats {
+        result.Stats = append(result.Stats, statValue{Name: stat.Stat.Name, Value: stat.BaseStat})
+    }
+    for _, pokeType := range pokemon.Types {
+        result.Types = append(result.Types, pokeType.Type.Name)
+    }
+    for _, ability := range pokemon.Abilities {
+        result.Abilities = append(result.Abilities, ability.Ability.Name)
+    }
+    return result
+}
+
+func (r inspectResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "%v: %v\n", "Name", r.Name)
+    fmt.Fprintf(w, "%v: %v\n", "Height", r.Height)
+    fmt.Fprintf(w, "%v: %v\n", "Weight", r.Weight)
+    fmt.Fprintf(w, "%v:\n", "Stats")
+    for _, stat := range r.Stats {
+        fmt.Fprintf(w, "    - %v: %v\n", stat.Name, stat.Value)
+    }
+    fmt.Fprintf(w, "%v:\n", "Types")
+    for _, pokeType := range r.Types {
+        fmt.Fprintf(w, "    - %v\n", pokeType)
+    }
+    fmt.Fprintf(w, "%v:\n", "Caught")
+    for _, caught := range r.Captures {
+        fmt.Fprintf(w, "    - %v\n", describeCapture(caught))
+    }
+}
+
+type pokedexResult struct {
+    Pokemon []pokedexEntry  `json:"pokemon"`
+    Seen    int             `json:"seen"`
+    Caught  int             `json:"caught"`
+}
+
+type pokedexEntry struct {
+    ID              int                         `json:"id"`
+    Name            string                      `json:"name"`
+    Types           []string                    `json:"types"`
+    Height          int                         `json:"height"`
+    Weight          int                         `json:"weight"`
+    BaseExperience  int                         `json:"base_experience"`
+    FirstCaught     *time.Time                  `json:"first_caught,omitempty"`
+    Captures        []internal.CaughtPokemon    `json:"captures"`
+}
+
+func newPokedexEntry(entry internal.DexEntry) pokedexEntry {
+    result := pokedexEntry{
+        ID:             entry.Pokemon.ID,
+        Name:           entry.Pokemon.Name,
+        Types:          []string{},
+        Height:         entry.Pokemon.Height,
+        Weight:         entry.Pokemon.Weight,
+        BaseExperience: entry.Pokemon.BaseExperience,
+        Captures:       entry.Captures,
+    }
+    for _, pokeType := range entry.Pokemon.Types {
+        result.Types = append(result.Types, pokeType.Type.Name)
+    }
+    if firstCaught := entry.FirstCaught(); !firstCaught.IsZero() {
+        result.FirstCaught = &firstCaught
+    }
+    return result
+}
+
+func (r pokedexResult) renderText(w io.Writer) {
+    if len(r.Pokemon) == 0 {
+        fmt.Fprintln(w, "No Entries")
+    } else {
+        table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+        fmt.Fprintln(table, "ID\tNAME\tTYPES\tHEIGHT\tWEIGHT\tBASE EXP\tOWNED\tFIRST CAUGHT\tNICKNAMES")
+        for _, entry := range r.Pokemon {
+            firstCaught := "-"
+            if entry.FirstCaught != nil {
+                firstCaught = entry.FirstCaught.Format("2006-01-02 15:04")
+            }
+            nicknames := []string{}
+            for _, caught := range entry.Captures {
+                if caught.Nickname != "" {
+                    nicknames = append(nicknames, caught.Nickname)
+                }
+            }
+            if len(nicknames) == 0 {
+                nicknames = append(nicknames, "-")
+            }
+            fmt.Fprintf(table, "%d\t%v\t%v\t%d\t%d\t%d\t%d\t%v\t%v\n", entry.ID, entry.Name, strings.Join(entry.Types, "/"), entry.Height, entry.Weight, entry.BaseExperience, len(entry.Captures), firstCaught, strings.Join(nicknames, ", "))
+        }
+        table.Flush()
+    }
+    fmt.Fprintf(w, "Showing %d, Seen: %d, Caught: %d\n", len(r.Pokemon), r.Seen, r.Caught)
+}
+
+type boxResult struct {
+    Pokemon []internal.CaughtPokemon `json:"pokemon"`
+}
+
+func (r boxResult) renderText(w io.Writer) {
+    if len(r.Pokemon) == 0 {
+        fmt.Fprintln(w, "Your box is empty")
+        return
+    }
+    for _, caught := range r.Pokemon {
+        fmt.Fprintf(w, " %v\n", describeCapture(caught))
+    }
+}
+
+//One line summary of a capture. Pokemon migrated from old save files have no level, origin or time
+func describeCapture(caught internal.CaughtPokemon) string {
+    desc := fmt.Sprintf("#%-4d %v", caught.ID, caught.Species)
+    if caught.Nickname != "" {
+        desc = fmt.Sprintf("#%-4d %v the %v", caught.ID, caught.Nickname, caught.Species)
+    }
+    if caught.Level > 0 {
+        desc += fmt.Sprintf(", level %v", caught.Level)
+    } else {
+        desc += ", level ?"
+    }
+    if caught.Location != "" {
+        desc += ", caught in " + caught.Location
+    }
+    if caught.Method != "" {
+        desc += fmt.Sprintf(" (%v, levels %v-%v)", caught.Method, caught.MinLevel, caught.MaxLevel)
+    }
+    if !caught.CaughtAt.IsZero() {
+        desc += " on " + caught.CaughtAt.Format("2006-01-02 15:04")
+    }
+    return desc
+}
+
+type releaseResult struct {
+    Pokemon internal.CaughtPokemon `json:"released"`
+}
+
+func (r releaseResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "%v #%v was released. Bye %v!\n", r.Pokemon.Species, r.Pokemon.ID, r.Pokemon.DisplayName())
+}
+
+type nicknameResult struct {
+    Previous    string                  `json:"previous"`
+    Pokemon     internal.CaughtPokemon  `json:"pokemon"`
+}
+
+func (r nicknameResult) renderText(w io.Writer) {
+    if r.Pokemon.Nickname == "" {
+        fmt.Fprintf(w, "%v #%v is called %v again\n", r.Previous, r.Pokemon.ID, r.Pokemon.Species)
+        return
+    }
+    fmt.Fprintf(w, "%v #%v is now called %v\n", r.Previous, r.Pokemon.ID, r.Pokemon.Nickname)
+}
+
+type cacheStatusResult struct {
+    Offline bool     `json:"offline"`
+    Areas   []string `json:"location_areas"`
+    Pokemon []string `json:"pokemon"`
+}
+
+func (r cacheStatusResult) renderText(w io.Writer) {
+    if r.Offline {
+        fmt.Fprintln(w, "Offline mode, only the entries below are available")
+    }
+    fmt.Fprintf(w, "Location areas available offline (%d):\n", len(r.Areas))
+    for _, area := range r.Areas {
+        fmt.Fprintf(w, " - %v\n", area)
+    }
+    fmt.Fprintf(w, "Pokemon available offline (%d):\n", len(r.Pokemon))
+    for _, pokemon := range r.Pokemon {
+        fmt.Fprintf(w, " - %v\n", pokemon)
+    }
+}
+
+type prefetchResult internal.PrefetchSummary
+
+func (r prefetchResult) MarshalJSON() ([]byte, error) {
+    return json.Marshal(struct {
+        Areas       int     `json:"areas"`
+        Pokemon     int     `json:"pokemon"`
+        Fetched     int     `json:"fetched"`
+        Skipped     int     `json:"skipped"`
+        Failed      int     `json:"failed"`
+        NotFound    int     `json:"not_found"`
+        Bytes       int64   `json:"bytes"`
+    }(r))
+}
+
+func (r prefetchResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "Prefetched %d location areas and %d pokemon\n", r.Areas, r.Pokemon)
+    fmt.Fprintf(w, "Stored %d entries (%d bytes), %d were already cached", r.Fetched, r.Bytes, r.Skipped)
+    if r.NotFound > 0 {
+        fmt.Fprintf(w, ", %d do not exist", r.NotFound)
+    }
+    if r.Failed > 0 {
+        fmt.Fprintf(w, ", %d failed, run it again to retry them", r.Failed)
+    }
+    fmt.Fprintln(w)
+}
+
+type cacheStatsResult struct {
+    Entries     int        `json:"entries"`
+    Bytes       int64      `json:"bytes"`
+    DiskEntries int        `json:"disk_entries"`
+    DiskBytes   int64      `json:"disk_bytes"`
+    Hits        int64      `json:"hits"`
+    Misses      int64      `json:"misses"`
+    HitRatio    float64    `json:"hit_ratio"`
+    OldestKey   string     `json:"oldest_key,omitempty"`
+    OldestAt    *time.Time `json:"oldest_at,omitempty"`
+}
+
+func newCacheStatsResult(stats internal.CacheStats) cacheStatsResult {
+    result := cacheStatsResult{
+        Entries:        stats.Entries,
+        Bytes:          stats.Bytes,
+        DiskEntries:    stats.DiskEntries,
+        DiskBytes:      stats.DiskBytes,
+        Hits:           stats.Hits,
+        Misses:         stats.Misses,
+        OldestKey:      stats.OldestKey,
+    }
+    if lookups := stats.Hits + stats.Misses; lookups > 0 {
+        result.HitRatio = float64(stats.Hits) / float64(lookups)
+    }
+    if stats.OldestKey != "" {
+        result.OldestAt = &stats.OldestAt
+    }
+    return result
+}
+
+func (r cacheStatsResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "Entries in memory: %d (%d bytes)\n", r.Entries, r.Bytes)
+    fmt.Fprintf(w, "Entries on disk: %d (%d bytes)\n", r.DiskEntries, r.DiskBytes)
+    fmt.Fprintf(w, "Hits: %d, misses: %d, hit ratio: %.1f%%\n", r.Hits, r.Misses, r.HitRatio*100)
+    if r.OldestAt != nil {
+        fmt.Fprintf(w, "Oldest entry: %v (%v ago)\n", r.OldestKey, time.Since(*r.OldestAt).Round(time.Second))
+    }
+}
+
+type cacheListResult struct {
+    Keys []string `json:"keys"`
+}
+
+func (r cacheListResult) renderText(w io.Writer) {
+    for _, key := range r.Keys {
+        fmt.Fprintln(w, key)
+    }
+}
+
+type cacheEvictResult struct {
+    Evicted int `json:"evicted"`
+}
+
+func (r cacheEvictResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "Evicted %d entries\n", r.Evicted)
+}
+
+type searchResult struct {
+    Query       string                  `json:"query"`
+    Matches     []internal.SearchMatch  `json:"matches"`
+    Suggestions []internal.SearchMatch  `json:"suggestions"`
+}
+
+func (r searchResult) renderText(w io.Writer) {
+    if len(r.Matches) == 0 {
+        fmt.Fprintf(w, "No matches for %v\n", r.Query)
+    } else {
+        table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+        for _, match := range r.Matches {
+            fmt.Fprintf(table, " %v\t%v\t%v\n", match.Kind, match.Name, strings.Join(match.Sources, ", "))
+        }
+        table.Flush()
+    }
+    if len(r.Suggestions) == 0 {
+        return
+    }
+    fmt.Fprintln(w, "Did you mean:")
+    for _, suggestion := range r.Suggestions {
+        fmt.Fprintf(w, " - %v (%v)\n", suggestion.Name, suggestion.Kind)
+    }
+}
+
+type progressResult struct {
+    Dex             string                      `json:"dex"`
+    Total           int                         `json:"total"`
+    Seen            int                         `json:"seen"`
+    Caught          int                         `json:"caught"`
+    SeenPercent     float64                     `json:"seen_percent"`
+    CaughtPercent   float64                     `json:"caught_percent"`
+    MissingCount    int                         `json:"missing_count"`
+    Missing         []internal.MissingSpecies   `json:"missing"`
+}
+
+//limit caps the listed missing species, 0 lists all of them
+func newProgressResult(progress internal.DexProgress, limit int) progressResult {
+    result := progressResult{
+        Dex:            progress.Dex,
+        Total:          progress.Total,
+        Seen:           progress.Seen,
+        Caught:         progress.Caught,
+        MissingCount:   len(progress.Missing),
+        Missing:        progress.Missing,
+    }
+    if progress.Total > 0 {
+        result.SeenPercent = 100 * float64(progress.Seen) / float64(progress.Total)
+        result.CaughtPercent = 100 * float64(progress.Caught) / float64(progress.Total)
+    }
+    if limit > 0 && len(result.Missing) > limit {
+        result.Missing = result.Missing[:limit]
+    }
+    return result
+}
+
+func (r progressResult) renderText(w io.Writer) {
+    fmt.Fprintf(w, "%v dex: %d species\n", r.Dex, r.Total)
+    fmt.Fprintf(w, "Seen:   %d/%d (%.1f%%)\n", r.Seen, r.Total, r.SeenPercent)
+    fmt.Fprintf(w, "Caught: %d/%d (%.1f%%)\n", r.Caught, r.Total, r.CaughtPercent)
+    if r.MissingCount == 0 {
+        fmt.Fprintln(w, "Nothing missing, the dex is complete!")
+        return
+    }
+    fmt.Fprintf(w, "Missing (%d):\n", r.MissingCount)
+    table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+    for _, missing := range r.Missing {
+        seen := ""
+        if missing.Seen {
+            seen = "seen"
+        }
+        areas := "not in any cached area"
+        if len(missing.Areas) > 0 {
+            areas = strings.Join(missing.Areas, ", ")
+        }
+        fmt.Fprintf(table, " #%03d\t%v\t%v\t%v\n", missing.Number, missing.Name, seen, areas)
+    }
+    table.Flush()
+    if len(r.Missing) < r.MissingCount {
+        fmt.Fprintf(w, " ... and %d more\n", r.MissingCount-len(r.Missing))
+    }
+}